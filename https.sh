@@ -0,0 +1,7 @@
+#!/bin/bash
+# Serve the site over HTTPS with the snakeoil self-signed certificate and redirect HTTP to it
+sudo apt-get install -y apache2 ssl-cert
+sudo a2enmod ssl rewrite
+sudo a2ensite default-ssl
+sudo sed -i 's#</VirtualHost>#\tRewriteEngine On\n\tRewriteRule ^ https://%{HTTP_HOST}%{REQUEST_URI} [R=301,L]\n</VirtualHost>#' /etc/apache2/sites-available/000-default.conf
+sudo systemctl restart apache2
@@ -1,4 +1,4 @@
 #!/bin/bash
 sudo apt-get update
 sudo apt-get install -y apache2
-
+sudo touch /var/log/a05-init.done
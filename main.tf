@@ -1,15 +1,46 @@
 # Define the resource group
 resource "azurerm_resource_group" "rg" {
-  name     = "${var.labelPrefix}-A05-RG"
+  name     = var.resource_group_suffix == "" ? "${var.labelPrefix}-A05-RG" : "${var.labelPrefix}-A05-RG-${var.resource_group_suffix}"
   location = var.region
+  tags     = local.tags
+
+  # created_at records the first apply; later applies would otherwise keep moving it forward
+  lifecycle {
+    ignore_changes = [tags["created_at"]]
+  }
 }
 
-# Define a public IP address
+locals {
+  tags = {
+    created_at = var.created_at != "" ? var.created_at : timestamp()
+  }
+}
+
+# Define a public IP address; private-only VMs skip it and are reached through Bastion
 resource "azurerm_public_ip" "webserver" {
+  count               = var.public_ip_enabled ? 1 : 0
   name                = "${var.labelPrefix}A05PublicIP"
   location            = azurerm_resource_group.rg.location
   resource_group_name = azurerm_resource_group.rg.name
-  allocation_method   = "Dynamic"
+  allocation_method   = var.public_ip_allocation
+  sku                 = var.public_ip_sku
+
+  # A Standard IP sits in the VM's zone, or is zone-redundant for a regional VM
+  zones = var.public_ip_sku == "Standard" ? (var.zone != "" ? [var.zone] : ["1", "2", "3"]) : null
+
+  idle_timeout_in_minutes = var.public_ip_idle_timeout
+
+  lifecycle {
+    precondition {
+      condition     = var.public_ip_sku != "Standard" || var.public_ip_allocation == "Static"
+      error_message = "A Standard public IP must use public_ip_allocation = Static."
+    }
+  }
+}
+
+moved {
+  from = azurerm_public_ip.webserver
+  to   = azurerm_public_ip.webserver[0]
 }
 
 # Define the virtual network
@@ -21,12 +52,81 @@ resource "azurerm_virtual_network" "vnet" {
 }
 
 
-# Define the subnet
+# Define the subnets; a single subnet keeps the original name
 resource "azurerm_subnet" "webserver" {
-  name                 = "${var.labelPrefix}A05Subnet"
+  for_each             = var.subnets
+  name                 = length(var.subnets) == 1 ? "${var.labelPrefix}A05Subnet" : "${var.labelPrefix}A05${title(each.key)}Subnet"
   resource_group_name  = azurerm_resource_group.rg.name
   virtual_network_name = azurerm_virtual_network.vnet.name
-  address_prefixes     = ["10.0.1.0/24"]
+  address_prefixes     = [each.value]
+
+  private_endpoint_network_policies_enabled = var.private_endpoint_policies_enabled
+}
+
+moved {
+  from = azurerm_subnet.webserver
+  to   = azurerm_subnet.webserver["web"]
+}
+
+# Text the landing page shows, tying the served content back to this deployment
+locals {
+  index_marker = "Hello from ${var.labelPrefix}"
+}
+
+# The hypervisor generation the image should boot as; Ubuntu Gen2 SKUs end in -gen2
+locals {
+  hyper_v_generation = var.hyper_v_generation != "" ? var.hyper_v_generation : (endswith(var.image_sku, "-gen2") || endswith(var.image_sku, "-arm64") ? "V2" : "V1")
+}
+
+# Ampere (Arm64) sizes have a p after the core count, such as Standard_D2ps_v5 or Standard_B2pts_v2, and
+# boot only Arm64 images, which Ubuntu publishes as -arm64 SKUs (Gen2 only)
+locals {
+  arm64_size = can(regex("^Standard_[A-Z]+[0-9]+[a-z]*p[a-z]*_v[0-9]+$", var.vm_size))
+}
+
+# Run a fixed number of Apache (event MPM) child processes: start that many, allow no more, and keep
+# enough spare threads that idle children aren't reaped. Each child runs 25 threads by default.
+locals {
+  apache_workers_conf = <<-EOT
+    <IfModule mpm_event_module>
+      StartServers ${var.web_server_workers}
+      ServerLimit ${var.web_server_workers}
+      MaxRequestWorkers ${var.web_server_workers * 25}
+      MinSpareThreads 25
+      MaxSpareThreads ${var.web_server_workers * 25}
+    </IfModule>
+  EOT
+}
+
+# Where the web server (Apache from init.sh) logs and how its logs are rotated
+locals {
+  web_server_logs = {
+    access_log       = "/var/log/apache2/access.log"
+    error_log        = "/var/log/apache2/error.log"
+    logrotate_config = "/etc/logrotate.d/apache2"
+  }
+}
+
+# Marketplace images that carry a plan need its terms accepted in the subscription before deploying
+locals {
+  marketplace_plan = var.plan_name != ""
+}
+
+# Linux license types are tied to the distro's publisher: Azure Hybrid Benefit only covers RHEL and SLES,
+# and Ubuntu Pro only Canonical images. Shared gallery images don't say who published them.
+locals {
+  image_publisher   = local.marketplace_plan ? var.plan_publisher : "Canonical"
+  license_publisher = lookup({ RHEL = "RedHat", SLES = "SUSE", UBUNTU = "Canonical" }, split("_", var.license_type)[0], "")
+}
+
+# Outbound rules applied when egress is restricted; allow exceptions must sort before the deny-all
+locals {
+  egress_rules = var.restrict_egress ? [
+    { name = "AllowHTTPOutbound", priority = 100, access = "Allow", protocol = "Tcp", port = "80", destination = "Internet" },
+    { name = "AllowHTTPSOutbound", priority = 110, access = "Allow", protocol = "Tcp", port = "443", destination = "Internet" },
+    { name = "AllowVnetOutbound", priority = 120, access = "Allow", protocol = "*", port = "*", destination = "VirtualNetwork" },
+    { name = "DenyAllOutbound", priority = 4096, access = "Deny", protocol = "*", port = "*", destination = "*" },
+  ] : []
 }
 
 # Define network security group and rules
@@ -58,6 +158,51 @@ resource "azurerm_network_security_group" "webserver" {
     source_address_prefix      = "*"
     destination_address_prefix = "*"
   }
+
+  dynamic "security_rule" {
+    for_each = var.enable_https ? [1] : []
+    content {
+      name                       = "HTTPS"
+      priority                   = 1003
+      direction                  = "Inbound"
+      access                     = "Allow"
+      protocol                   = "Tcp"
+      source_port_range          = "*"
+      destination_port_range     = "443"
+      source_address_prefix      = "*"
+      destination_address_prefix = "*"
+    }
+  }
+
+  dynamic "security_rule" {
+    for_each = var.os_type == "windows" ? [1] : []
+    content {
+      name                       = "RDP"
+      priority                   = 1004
+      direction                  = "Inbound"
+      access                     = "Allow"
+      protocol                   = "Tcp"
+      source_port_range          = "*"
+      destination_port_range     = "3389"
+      source_address_prefix      = "*"
+      destination_address_prefix = "*"
+    }
+  }
+
+  dynamic "security_rule" {
+    for_each = local.egress_rules
+    content {
+      name                       = security_rule.value.name
+      priority                   = security_rule.value.priority
+      direction                  = "Outbound"
+      access                     = security_rule.value.access
+      protocol                   = security_rule.value.protocol
+      source_port_range          = "*"
+      destination_port_range     = security_rule.value.port
+      source_address_prefix      = "*"
+      destination_address_prefix = security_rule.value.destination
+    }
+  }
 }
 
 # Define the network interface
@@ -66,11 +211,49 @@ resource "azurerm_network_interface" "webserver" {
   location            = azurerm_resource_group.rg.location
   resource_group_name = azurerm_resource_group.rg.name
 
+  lifecycle {
+    precondition {
+      condition     = contains(keys(var.subnets), var.nic_subnet_name)
+      error_message = "The nic_subnet_name ${var.nic_subnet_name} is not one of the subnets: ${join(", ", keys(var.subnets))}."
+    }
+
+    precondition {
+      condition     = contains(["", "Dynamic"], var.secondary_private_ip) || try(cidrhost("${var.secondary_private_ip}/${split("/", var.subnets[var.nic_subnet_name])[1]}", 0) == cidrhost(var.subnets[var.nic_subnet_name], 0), false)
+      error_message = "The secondary_private_ip ${var.secondary_private_ip} is not in the NIC's subnet ${lookup(var.subnets, var.nic_subnet_name, "")}."
+    }
+  }
+
   ip_configuration {
     name                          = "${var.labelPrefix}A05NicConfig"
-    subnet_id                     = azurerm_subnet.webserver.id
+    subnet_id                     = azurerm_subnet.webserver[var.nic_subnet_name].id
+    private_ip_address_allocation = "Dynamic"
+    public_ip_address_id          = var.public_ip_enabled ? azurerm_public_ip.webserver[0].id : null
+    primary                       = true
+  }
+
+  # An optional second private IP on the same NIC, either Dynamic or a static address in the NIC's subnet
+  dynamic "ip_configuration" {
+    for_each = var.secondary_private_ip != "" ? [1] : []
+    content {
+      name                          = "${var.labelPrefix}A05NicConfig2"
+      subnet_id                     = azurerm_subnet.webserver[var.nic_subnet_name].id
+      private_ip_address_allocation = var.secondary_private_ip == "Dynamic" ? "Dynamic" : "Static"
+      private_ip_address            = var.secondary_private_ip == "Dynamic" ? null : var.secondary_private_ip
+    }
+  }
+}
+
+# Additional NICs for multi-NIC labs; they stay private and the VM size must support the total count
+resource "azurerm_network_interface" "secondary" {
+  count               = var.nic_count - 1
+  name                = "${var.labelPrefix}A05Nic${count.index + 2}"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
+
+  ip_configuration {
+    name                          = "${var.labelPrefix}A05Nic${count.index + 2}Config"
+    subnet_id                     = azurerm_subnet.webserver[var.nic_subnet_name].id
     private_ip_address_allocation = "Dynamic"
-    public_ip_address_id          = azurerm_public_ip.webserver.id
   }
 }
 
@@ -80,41 +263,115 @@ resource "azurerm_network_interface_security_group_association" "webserver" {
   network_security_group_id = azurerm_network_security_group.webserver.id
 }
 
+# The VM's hostname, cut to the OS's limit rather than left for Azure to reject: 64 characters on Linux and
+# 15 (the NetBIOS limit) on Windows. Truncation keeps the leading characters, so the same prefix always gives
+# the same name; labelPrefix's 20-character cap keeps Linux names whole.
+locals {
+  computer_name_limit = var.os_type == "windows" ? 15 : 64
+  computer_name       = substr("${var.labelPrefix}A05VM", 0, local.computer_name_limit)
+}
+
 # Define the init script template
 data "cloudinit_config" "init" {
   gzip          = false
   base64_encode = true
 
   part {
-    filename     = "init.sh"
+    filename     = basename(var.custom_data_file)
     content_type = "text/x-shellscript"
 
-    content = file("${path.module}/init.sh")
+    content = file("${path.module}/${var.custom_data_file}")
+  }
+
+  # Runs after the bootstrap script, as cloud-init runs scripts in name order
+  dynamic "part" {
+    for_each = concat(var.enable_https ? ["https.sh"] : [], var.enable_metrics ? ["metrics.sh"] : [], var.gzip_enabled ? [] : ["no-gzip.sh"])
+    content {
+      filename     = "zz-${part.value}"
+      content_type = "text/x-shellscript"
+
+      content = file("${path.module}/${part.value}")
+    }
   }
+
+  # Write the landing page before apache2 is installed; its package only adds the default page to an empty docroot
+  part {
+    content_type = "text/cloud-config"
+
+    content = join("\n", ["#cloud-config", yamlencode({
+      write_files = [{
+        path        = "/var/www/html/index.html"
+        permissions = "0644"
+        content     = "<html><body><h1>${local.index_marker}</h1></body></html>\n"
+        }, {
+        # Included after the MPM's own settings, so these win
+        path        = "/etc/apache2/conf-enabled/a05-workers.conf"
+        permissions = "0644"
+        content     = local.apache_workers_conf
+      }]
+    })])
+  }
+}
+
+# Optionally pin the VM to a proximity placement group so later VMs land physically close to it
+resource "azurerm_proximity_placement_group" "webserver" {
+  count               = var.enable_ppg ? 1 : 0
+  name                = "${var.labelPrefix}A05PPG"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
 }
 
 # Define the virtual machine
 resource "azurerm_linux_virtual_machine" "webserver" {
+  count                 = var.os_type == "linux" ? 1 : 0
   name                  = "${var.labelPrefix}A05VM"
   resource_group_name   = azurerm_resource_group.rg.name
   location              = azurerm_resource_group.rg.location
-  network_interface_ids = [azurerm_network_interface.webserver.id]
-  size                  = "Standard_B1s"
+  network_interface_ids = concat([azurerm_network_interface.webserver.id], azurerm_network_interface.secondary[*].id)
+  size                  = var.vm_size
+  zone                  = var.zone != "" ? var.zone : null
+
+  proximity_placement_group_id = var.enable_ppg ? azurerm_proximity_placement_group.webserver[0].id : null
 
   os_disk {
     name                 = "${var.labelPrefix}A05OSDisk"
-    caching              = "ReadWrite"
+    caching              = var.os_disk_caching
     storage_account_type = "Standard_LRS"
+
+    # An ephemeral OS disk lives on the host's local temp disk: free and fast, but lost on deallocation
+    dynamic "diff_disk_settings" {
+      for_each = var.ephemeral_os_disk ? [1] : []
+      content {
+        option    = "Local"
+        placement = "ResourceDisk"
+      }
+    }
   }
 
-  source_image_reference {
-    publisher = "Canonical"
-    offer     = "0001-com-ubuntu-server-jammy"
-    sku       = "22_04-lts-gen2"
-    version   = "latest"
+  # A shared image gallery image replaces the marketplace image entirely
+  source_image_id = var.shared_image_id != "" ? var.shared_image_id : null
+
+  # A marketplace plan's image is addressed by the plan's publisher, product and name
+  dynamic "source_image_reference" {
+    for_each = var.shared_image_id == "" ? [1] : []
+    content {
+      publisher = local.image_publisher
+      offer     = local.marketplace_plan ? var.plan_product : var.image_offer
+      sku       = local.marketplace_plan ? var.plan_name : var.image_sku
+      version   = "latest"
+    }
+  }
+
+  dynamic "plan" {
+    for_each = local.marketplace_plan ? [1] : []
+    content {
+      name      = var.plan_name
+      publisher = var.plan_publisher
+      product   = var.plan_product
+    }
   }
 
-  computer_name                   = "${var.labelPrefix}A05VM"
+  computer_name                   = local.computer_name
   admin_username                  = var.admin_username
   disable_password_authentication = true
 
@@ -124,4 +381,478 @@ resource "azurerm_linux_virtual_machine" "webserver" {
   }
 
   custom_data = data.cloudinit_config.init.rendered
+
+  disk_controller_type = var.disk_controller_type
+
+  # Bring-your-own-subscription or hybrid benefit licensing; null bills the image's default
+  license_type = var.license_type != "" ? var.license_type : null
+
+  # Trusted launch; Azure sets the security type to TrustedLaunch when either is enabled
+  secure_boot_enabled = var.secure_boot_enabled
+  vtpm_enabled        = var.vtpm_enabled
+
+  tags = local.tags
+
+  # managed_externally belongs to outside tagging systems (cost, compliance), so applies leave it alone
+  lifecycle {
+    ignore_changes = [tags["created_at"], tags["managed_externally"]]
+
+    precondition {
+      condition     = !(var.secure_boot_enabled || var.vtpm_enabled) || local.hyper_v_generation == "V2"
+      error_message = "Trusted launch (secure_boot_enabled or vtpm_enabled) requires a Gen2 image, but image_sku ${var.image_sku} boots as ${local.hyper_v_generation}; set hyper_v_generation = \"V2\" if it is Gen2."
+    }
+
+    precondition {
+      condition     = var.disk_controller_type != "NVMe" || (local.hyper_v_generation == "V2" && can(regex("(_v[6-9]|^Standard_E[0-9]+b[d]?s_v5)$", var.vm_size)))
+      error_message = "The NVMe disk controller requires a Gen2 image and an NVMe-capable size (v6 or later, or Ebsv5), but the image boots as ${local.hyper_v_generation} and vm_size is ${var.vm_size}."
+    }
+
+    precondition {
+      condition     = (var.plan_name == "") == (var.plan_publisher == "") && (var.plan_name == "") == (var.plan_product == "")
+      error_message = "The plan_name, plan_publisher and plan_product must be set together."
+    }
+
+    precondition {
+      condition     = var.shared_image_id == "" || !local.marketplace_plan
+      error_message = "The shared_image_id can't be combined with a marketplace plan; the plan only applies to marketplace images."
+    }
+
+    precondition {
+      condition     = !var.ephemeral_os_disk || (var.os_disk_caching == "ReadOnly" && can(regex("^Standard_[A-Z]+[0-9]+[a-z]*d[a-z]*_v[4-9]$", var.vm_size)))
+      error_message = "An ephemeral OS disk needs os_disk_caching = ReadOnly and a size with a local temp disk large enough for the image (a v4 or later size with d, such as Standard_D2ds_v5), but vm_size is ${var.vm_size}."
+    }
+
+    precondition {
+      condition     = var.license_type == "" || var.shared_image_id != "" || local.license_publisher == local.image_publisher
+      error_message = "The license_type ${var.license_type} only applies to ${local.license_publisher} images, but the image publisher is ${local.image_publisher}."
+    }
+
+    precondition {
+      condition     = local.arm64_size == (var.architecture == "Arm64")
+      error_message = "The vm_size ${var.vm_size} is ${local.arm64_size ? "an Arm64" : "an x64"} size, but architecture is ${var.architecture}."
+    }
+
+    precondition {
+      condition     = var.shared_image_id != "" || local.marketplace_plan || endswith(var.image_sku, "-arm64") == (var.architecture == "Arm64")
+      error_message = "The image_sku ${var.image_sku} is ${endswith(var.image_sku, "-arm64") ? "an Arm64" : "an x64"} image, but architecture is ${var.architecture}; Arm64 sizes need an -arm64 SKU such as 22_04-lts-arm64."
+    }
+  }
+}
+
+moved {
+  from = azurerm_linux_virtual_machine.webserver
+  to   = azurerm_linux_virtual_machine.webserver[0]
+}
+
+# The Windows variant: the same network, size and disk settings on a Windows Server image. cloud-init doesn't
+# run on Windows, so it serves no web site and is reached over RDP instead.
+resource "azurerm_windows_virtual_machine" "webserver" {
+  count                 = var.os_type == "windows" ? 1 : 0
+  name                  = "${var.labelPrefix}A05VM"
+  resource_group_name   = azurerm_resource_group.rg.name
+  location              = azurerm_resource_group.rg.location
+  network_interface_ids = concat([azurerm_network_interface.webserver.id], azurerm_network_interface.secondary[*].id)
+  size                  = var.vm_size
+  zone                  = var.zone != "" ? var.zone : null
+
+  proximity_placement_group_id = var.enable_ppg ? azurerm_proximity_placement_group.webserver[0].id : null
+
+  os_disk {
+    name                 = "${var.labelPrefix}A05OSDisk"
+    caching              = var.os_disk_caching
+    storage_account_type = "Standard_LRS"
+  }
+
+  source_image_reference {
+    publisher = "MicrosoftWindowsServer"
+    offer     = "WindowsServer"
+    sku       = var.windows_image_sku
+    version   = "latest"
+  }
+
+  computer_name  = local.computer_name
+  admin_username = var.admin_username
+  admin_password = var.admin_password
+
+  secure_boot_enabled = var.secure_boot_enabled
+  vtpm_enabled        = var.vtpm_enabled
+
+  tags = local.tags
+
+  lifecycle {
+    ignore_changes = [tags["created_at"], tags["managed_externally"]]
+
+    precondition {
+      condition     = var.admin_password != ""
+      error_message = "A Windows VM needs an admin_password."
+    }
+
+    precondition {
+      condition     = var.architecture == "x64" && !local.arm64_size
+      error_message = "The Windows VM only supports x64 sizes, but architecture is ${var.architecture} and vm_size is ${var.vm_size}."
+    }
+  }
+}
+
+# The VM, whichever OS it runs; exactly one of the two VM resources exists
+locals {
+  vm = one(concat(
+    [for vm in azurerm_linux_virtual_machine.webserver : {
+      id                   = vm.id
+      name                 = vm.name
+      public_ip_address    = vm.public_ip_address
+      size                 = vm.size
+      admin_username       = vm.admin_username
+      os_disk_caching      = vm.os_disk[0].caching
+      secure_boot_enabled  = vm.secure_boot_enabled
+      vtpm_enabled         = vm.vtpm_enabled
+      disk_controller_type = vm.disk_controller_type
+    }],
+    [for vm in azurerm_windows_virtual_machine.webserver : {
+      id                   = vm.id
+      name                 = vm.name
+      public_ip_address    = vm.public_ip_address
+      size                 = vm.size
+      admin_username       = vm.admin_username
+      os_disk_caching      = vm.os_disk[0].caching
+      secure_boot_enabled  = vm.secure_boot_enabled
+      vtpm_enabled         = vm.vtpm_enabled
+      disk_controller_type = ""
+    }],
+  ))
+}
+
+# Optionally keep the data disks in their own resource group. Azure puts an image-based VM's OS disk in the
+# VM's resource group, so only the data disks can move.
+resource "azurerm_resource_group" "disks" {
+  count    = var.disk_resource_group_name != "" ? 1 : 0
+  name     = var.disk_resource_group_name
+  location = azurerm_resource_group.rg.location
+  tags     = azurerm_resource_group.rg.tags
+}
+
+locals {
+  disk_resource_group_name = var.disk_resource_group_name != "" ? azurerm_resource_group.disks[0].name : azurerm_resource_group.rg.name
+}
+
+# Optional data disks, attached in list order at LUNs 0, 1, ... with each disk's own caching
+resource "azurerm_managed_disk" "data" {
+  count                = length(var.data_disks)
+  name                 = "${var.labelPrefix}A05DataDisk${count.index}"
+  location             = azurerm_resource_group.rg.location
+  resource_group_name  = local.disk_resource_group_name
+  storage_account_type = "Standard_LRS"
+  create_option        = "Empty"
+  disk_size_gb         = var.data_disks[count.index].size_gb
+  zone                 = var.zone != "" ? var.zone : null
+}
+
+resource "azurerm_virtual_machine_data_disk_attachment" "data" {
+  count              = length(var.data_disks)
+  managed_disk_id    = azurerm_managed_disk.data[count.index].id
+  virtual_machine_id = local.vm.id
+  lun                = count.index
+  caching            = var.data_disks[count.index].caching
+}
+
+# Optionally point a record in an existing public DNS zone at the VM. It is an alias of the public IP, so it
+# follows the address even when a Dynamic IP changes.
+data "azurerm_dns_zone" "custom" {
+  count               = var.dns_zone_name != "" ? 1 : 0
+  name                = var.dns_zone_name
+  resource_group_name = var.dns_zone_resource_group
+
+  lifecycle {
+    precondition {
+      condition     = var.dns_zone_resource_group != ""
+      error_message = "The dns_zone_resource_group must be set with dns_zone_name."
+    }
+  }
+}
+
+resource "azurerm_dns_a_record" "webserver" {
+  count               = var.dns_zone_name != "" ? 1 : 0
+  name                = var.dns_record_name
+  zone_name           = data.azurerm_dns_zone.custom[0].name
+  resource_group_name = data.azurerm_dns_zone.custom[0].resource_group_name
+  ttl                 = 60
+  target_resource_id  = azurerm_public_ip.webserver[0].id
+
+  lifecycle {
+    precondition {
+      condition     = var.public_ip_enabled
+      error_message = "A DNS record (dns_zone_name) needs the VM's public IP; set public_ip_enabled = true."
+    }
+  }
+}
+
+# Optionally shut the VM down daily so lab VMs don't run overnight
+resource "azurerm_dev_test_global_vm_shutdown_schedule" "webserver" {
+  count                 = var.auto_shutdown_time != "" ? 1 : 0
+  virtual_machine_id    = local.vm.id
+  location              = azurerm_resource_group.rg.location
+  enabled               = true
+  daily_recurrence_time = var.auto_shutdown_time
+  timezone              = var.auto_shutdown_timezone
+
+  notification_settings {
+    enabled = false
+  }
+}
+
+# Optionally install the Azure Monitor agent extension
+resource "azurerm_virtual_machine_extension" "monitor_agent" {
+  count                      = var.install_monitor_agent ? 1 : 0
+  name                       = var.os_type == "windows" ? "AzureMonitorWindowsAgent" : "AzureMonitorLinuxAgent"
+  virtual_machine_id         = local.vm.id
+  publisher                  = "Microsoft.Azure.Monitor"
+  type                       = var.os_type == "windows" ? "AzureMonitorWindowsAgent" : "AzureMonitorLinuxAgent"
+  type_handler_version       = "1.0"
+  auto_upgrade_minor_version = true
+}
+
+# Optionally provision a bastion host; Azure requires the subnet name and at least a /26
+resource "azurerm_subnet" "bastion" {
+  count                = var.enable_bastion ? 1 : 0
+  name                 = "AzureBastionSubnet"
+  resource_group_name  = azurerm_resource_group.rg.name
+  virtual_network_name = azurerm_virtual_network.vnet.name
+  address_prefixes     = ["10.0.2.0/26"]
+}
+
+resource "azurerm_public_ip" "bastion" {
+  count               = var.enable_bastion ? 1 : 0
+  name                = "${var.labelPrefix}A05BastionIP"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
+  allocation_method   = "Static"
+  sku                 = "Standard"
+}
+
+resource "azurerm_bastion_host" "bastion" {
+  count               = var.enable_bastion ? 1 : 0
+  name                = "${var.labelPrefix}A05Bastion"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
+
+  ip_configuration {
+    name                 = "${var.labelPrefix}A05BastionConfig"
+    subnet_id            = azurerm_subnet.bastion[0].id
+    public_ip_address_id = azurerm_public_ip.bastion[0].id
+  }
+}
+
+# Optionally put the web server behind a Standard load balancer with an HTTP health probe
+resource "azurerm_public_ip" "lb" {
+  count               = var.enable_load_balancer ? 1 : 0
+  name                = "${var.labelPrefix}A05LBPublicIP"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
+  allocation_method   = "Static"
+  sku                 = "Standard"
+}
+
+resource "azurerm_lb" "webserver" {
+  count               = var.enable_load_balancer ? 1 : 0
+  name                = "${var.labelPrefix}A05LB"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
+  sku                 = "Standard"
+
+  frontend_ip_configuration {
+    name                 = "${var.labelPrefix}A05LBFrontend"
+    public_ip_address_id = azurerm_public_ip.lb[0].id
+  }
+
+  # Azure won't put a NIC with a Basic public IP in a Standard load balancer's backend pool
+  lifecycle {
+    precondition {
+      condition     = !var.public_ip_enabled || var.public_ip_sku == "Standard"
+      error_message = "The load balancer requires public_ip_enabled = false or public_ip_sku = \"Standard\", as the VM's Basic public IP can't join a Standard load balancer."
+    }
+  }
+}
+
+resource "azurerm_lb_backend_address_pool" "webserver" {
+  count           = var.enable_load_balancer ? 1 : 0
+  name            = "${var.labelPrefix}A05LBPool"
+  loadbalancer_id = azurerm_lb.webserver[0].id
+}
+
+resource "azurerm_network_interface_backend_address_pool_association" "webserver" {
+  count                   = var.enable_load_balancer ? 1 : 0
+  network_interface_id    = azurerm_network_interface.webserver.id
+  ip_configuration_name   = "${var.labelPrefix}A05NicConfig"
+  backend_address_pool_id = azurerm_lb_backend_address_pool.webserver[0].id
+}
+
+resource "azurerm_lb_probe" "http" {
+  count           = var.enable_load_balancer ? 1 : 0
+  name            = "${var.labelPrefix}A05LBProbe"
+  loadbalancer_id = azurerm_lb.webserver[0].id
+  protocol        = "Http"
+  port            = 80
+  request_path    = "/"
+}
+
+resource "azurerm_lb_rule" "http" {
+  count                          = var.enable_load_balancer ? 1 : 0
+  name                           = "${var.labelPrefix}A05LBRule"
+  loadbalancer_id                = azurerm_lb.webserver[0].id
+  protocol                       = "Tcp"
+  frontend_port                  = 80
+  backend_port                   = 80
+  frontend_ip_configuration_name = "${var.labelPrefix}A05LBFrontend"
+  backend_address_pool_ids       = [azurerm_lb_backend_address_pool.webserver[0].id]
+  probe_id                       = azurerm_lb_probe.http[0].id
+}
+
+# The subnets the application gateway's subnet would overlap. Two prefixes overlap when they share a network
+# at the shorter of their two prefix lengths.
+locals {
+  appgw_prefix_length = tonumber(split("/", var.app_gateway_subnet_prefix)[1])
+  appgw_overlapping_subnets = [
+    for name, prefix in var.subnets : name
+    if cidrhost("${cidrhost(prefix, 0)}/${min(tonumber(split("/", prefix)[1]), local.appgw_prefix_length)}", 0) == cidrhost("${cidrhost(var.app_gateway_subnet_prefix, 0)}/${min(tonumber(split("/", prefix)[1]), local.appgw_prefix_length)}", 0)
+  ]
+}
+
+# Optionally put an application gateway in front of the web server, forwarding HTTP on port 80 to the
+# NIC's private IP. The gateway needs a subnet of its own and a Standard public IP.
+resource "azurerm_subnet" "appgw" {
+  count                = var.enable_app_gateway ? 1 : 0
+  name                 = "${var.labelPrefix}A05AppGwSubnet"
+  resource_group_name  = azurerm_resource_group.rg.name
+  virtual_network_name = azurerm_virtual_network.vnet.name
+  address_prefixes     = [var.app_gateway_subnet_prefix]
+
+  # Azure's overlap error at apply doesn't say which subnet clashes, so catch it at plan
+  lifecycle {
+    precondition {
+      condition     = length(local.appgw_overlapping_subnets) == 0
+      error_message = "The app_gateway_subnet_prefix ${var.app_gateway_subnet_prefix} overlaps the subnets: ${join(", ", local.appgw_overlapping_subnets)}."
+    }
+  }
+}
+
+resource "azurerm_public_ip" "appgw" {
+  count               = var.enable_app_gateway ? 1 : 0
+  name                = "${var.labelPrefix}A05AppGwPublicIP"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
+  allocation_method   = "Static"
+  sku                 = "Standard"
+}
+
+locals {
+  appgw_names = {
+    gateway_ip_configuration = "${var.labelPrefix}A05AppGwIPConfig"
+    frontend_ip              = "${var.labelPrefix}A05AppGwFrontend"
+    frontend_port            = "${var.labelPrefix}A05AppGwPort"
+    backend_pool             = "${var.labelPrefix}A05AppGwPool"
+    backend_http_settings    = "${var.labelPrefix}A05AppGwHttpSettings"
+    listener                 = "${var.labelPrefix}A05AppGwListener"
+    rule                     = "${var.labelPrefix}A05AppGwRule"
+  }
+}
+
+resource "azurerm_application_gateway" "webserver" {
+  count               = var.enable_app_gateway ? 1 : 0
+  name                = "${var.labelPrefix}A05AppGw"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
+
+  sku {
+    name     = "Standard_v2"
+    tier     = "Standard_v2"
+    capacity = 1
+  }
+
+  gateway_ip_configuration {
+    name      = local.appgw_names.gateway_ip_configuration
+    subnet_id = azurerm_subnet.appgw[0].id
+  }
+
+  frontend_ip_configuration {
+    name                 = local.appgw_names.frontend_ip
+    public_ip_address_id = azurerm_public_ip.appgw[0].id
+  }
+
+  frontend_port {
+    name = local.appgw_names.frontend_port
+    port = 80
+  }
+
+  backend_address_pool {
+    name         = local.appgw_names.backend_pool
+    ip_addresses = [azurerm_network_interface.webserver.private_ip_address]
+  }
+
+  backend_http_settings {
+    name                  = local.appgw_names.backend_http_settings
+    cookie_based_affinity = "Disabled"
+    port                  = 80
+    protocol              = "Http"
+    request_timeout       = 30
+  }
+
+  http_listener {
+    name                           = local.appgw_names.listener
+    frontend_ip_configuration_name = local.appgw_names.frontend_ip
+    frontend_port_name             = local.appgw_names.frontend_port
+    protocol                       = "Http"
+  }
+
+  request_routing_rule {
+    name                       = local.appgw_names.rule
+    priority                   = 100
+    rule_type                  = "Basic"
+    http_listener_name         = local.appgw_names.listener
+    backend_address_pool_name  = local.appgw_names.backend_pool
+    backend_http_settings_name = local.appgw_names.backend_http_settings
+  }
+}
+
+# Optionally send the NSG's event and rule counter logs to a Log Analytics workspace
+locals {
+  nsg_log_categories = ["NetworkSecurityGroupEvent", "NetworkSecurityGroupRuleCounter"]
+}
+
+resource "azurerm_log_analytics_workspace" "diagnostics" {
+  count               = var.enable_nsg_diagnostics ? 1 : 0
+  name                = "${var.labelPrefix}A05Logs"
+  location            = azurerm_resource_group.rg.location
+  resource_group_name = azurerm_resource_group.rg.name
+  sku                 = "PerGB2018"
+  retention_in_days   = 30
+}
+
+resource "azurerm_monitor_diagnostic_setting" "nsg" {
+  count                      = var.enable_nsg_diagnostics ? 1 : 0
+  name                       = "${var.labelPrefix}A05NsgDiagnostics"
+  target_resource_id         = azurerm_network_security_group.webserver.id
+  log_analytics_workspace_id = azurerm_log_analytics_workspace.diagnostics[0].id
+
+  dynamic "enabled_log" {
+    for_each = local.nsg_log_categories
+    content {
+      category = enabled_log.value
+    }
+  }
+}
+
+# Optionally create a private DNS zone linked to the VNet for name resolution labs
+resource "azurerm_private_dns_zone" "internal" {
+  count               = var.enable_private_dns ? 1 : 0
+  name                = var.private_dns_zone_name
+  resource_group_name = azurerm_resource_group.rg.name
+}
+
+resource "azurerm_private_dns_zone_virtual_network_link" "internal" {
+  count                 = var.enable_private_dns ? 1 : 0
+  name                  = "${var.labelPrefix}A05DnsLink"
+  resource_group_name   = azurerm_resource_group.rg.name
+  private_dns_zone_name = azurerm_private_dns_zone.internal[0].name
+  virtual_network_id    = azurerm_virtual_network.vnet.id
+  registration_enabled  = var.private_dns_auto_registration
 }
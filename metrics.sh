@@ -0,0 +1,6 @@
+#!/bin/bash
+# Expose node_exporter's Prometheus metrics on the web server at /metrics
+sudo apt-get install -y apache2 prometheus-node-exporter
+sudo a2enmod proxy proxy_http
+sudo sed -i 's#</VirtualHost>#\tProxyPass /metrics http://127.0.0.1:9100/metrics\n\tProxyPassReverse /metrics http://127.0.0.1:9100/metrics\n</VirtualHost>#' /etc/apache2/sites-available/000-default.conf
+sudo systemctl restart apache2
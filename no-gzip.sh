@@ -0,0 +1,5 @@
+#!/bin/bash
+# Turn off gzip compression; Ubuntu's apache2 package enables mod_deflate for text types by default
+sudo apt-get install -y apache2
+sudo a2dismod -f deflate
+sudo systemctl restart apache2
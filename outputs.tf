@@ -3,8 +3,20 @@ output "resource_group_name" {
   value = azurerm_resource_group.rg.name
 }
 
+output "location" {
+  value = azurerm_resource_group.rg.location
+}
+
+output "os_type" {
+  value = var.os_type
+}
+
+output "architecture" {
+  value = var.architecture
+}
+
 output "vm_name" {
-  value = azurerm_linux_virtual_machine.webserver.name
+  value = local.vm.name
 }
 
 output "nic_name" {
@@ -12,5 +24,244 @@ output "nic_name" {
 }
 
 output "public_ip" {
-  value = azurerm_linux_virtual_machine.webserver.public_ip_address
+  value = local.vm.public_ip_address
+}
+
+output "monitor_agent_extension_name" {
+  value = var.install_monitor_agent ? azurerm_virtual_machine_extension.monitor_agent[0].name : ""
+}
+
+output "public_ip_name" {
+  value = var.public_ip_enabled ? azurerm_public_ip.webserver[0].name : ""
+}
+
+output "nsg_id" {
+  value = azurerm_network_security_group.webserver.id
+}
+
+output "nsg_name" {
+  value = azurerm_network_security_group.webserver.name
+}
+
+output "restrict_egress" {
+  value = var.restrict_egress
+}
+
+output "vnet_name" {
+  value = azurerm_virtual_network.vnet.name
+}
+
+output "bastion_name" {
+  value = var.enable_bastion ? azurerm_bastion_host.bastion[0].name : ""
+}
+
+output "vm_size" {
+  value = local.vm.size
+}
+
+output "admin_username" {
+  value = local.vm.admin_username
+}
+
+output "os_disk_caching" {
+  value = local.vm.os_disk_caching
+}
+
+output "custom_data_marker" {
+  value = var.custom_data_marker
+}
+
+output "nic_count" {
+  value = var.nic_count
+}
+
+output "secure_boot_enabled" {
+  value = local.vm.secure_boot_enabled
+}
+
+output "vtpm_enabled" {
+  value = local.vm.vtpm_enabled
+}
+
+output "index_marker" {
+  value = local.index_marker
+}
+
+output "subnet_ids" {
+  value = { for name, subnet in azurerm_subnet.webserver : name => subnet.id }
+}
+
+output "nic_subnet_name" {
+  value = var.nic_subnet_name
+}
+
+output "plan_name" {
+  value = var.plan_name
+}
+
+output "plan_publisher" {
+  value = var.plan_publisher
+}
+
+output "plan_product" {
+  value = var.plan_product
+}
+
+output "disk_controller_type" {
+  value = local.vm.disk_controller_type
+}
+
+output "private_dns_zone_name" {
+  value = var.enable_private_dns ? azurerm_private_dns_zone.internal[0].name : ""
+}
+
+output "private_dns_link_name" {
+  value = var.enable_private_dns ? azurerm_private_dns_zone_virtual_network_link.internal[0].name : ""
+}
+
+output "private_dns_auto_registration" {
+  value = var.enable_private_dns ? azurerm_private_dns_zone_virtual_network_link.internal[0].registration_enabled : false
+}
+
+output "https_enabled" {
+  value = var.enable_https
+}
+
+output "metrics_enabled" {
+  value = var.enable_metrics
+}
+
+output "gzip_enabled" {
+  value = var.gzip_enabled
+}
+
+output "vm_id" {
+  value = local.vm.id
+}
+
+output "private_endpoint_policies_enabled" {
+  value = var.private_endpoint_policies_enabled
+}
+
+output "hyper_v_generation" {
+  value = local.hyper_v_generation
+}
+
+output "zone" {
+  value = var.zone
+}
+
+output "public_ip_enabled" {
+  value = var.public_ip_enabled
+}
+
+output "public_ip_allocation" {
+  value = var.public_ip_allocation
+}
+
+output "public_ip_sku" {
+  value = var.public_ip_sku
+}
+
+output "tags" {
+  value = azurerm_resource_group.rg.tags
+}
+
+output "nics" {
+  value = concat(
+    [{
+      name       = azurerm_network_interface.webserver.name
+      private_ip = azurerm_network_interface.webserver.private_ip_address
+      primary    = true
+    }],
+    [for nic in azurerm_network_interface.secondary : {
+      name       = nic.name
+      private_ip = nic.private_ip_address
+      primary    = false
+    }],
+  )
+}
+
+output "secondary_private_ip" {
+  value = var.secondary_private_ip != "" ? azurerm_network_interface.webserver.private_ip_addresses[1] : ""
+}
+
+output "secondary_nic_names" {
+  value = azurerm_network_interface.secondary[*].name
+}
+
+output "auto_shutdown_time" {
+  value = var.auto_shutdown_time
+}
+
+output "auto_shutdown_schedule_name" {
+  value = var.auto_shutdown_time != "" ? azurerm_dev_test_global_vm_shutdown_schedule.webserver[0].name : ""
+}
+
+output "shared_image_id" {
+  value = var.shared_image_id
+}
+
+output "public_ip_idle_timeout" {
+  value = var.public_ip_idle_timeout
+}
+
+output "load_balancer_name" {
+  value = var.enable_load_balancer ? azurerm_lb.webserver[0].name : ""
+}
+
+output "load_balancer_ip" {
+  value = var.enable_load_balancer ? azurerm_public_ip.lb[0].ip_address : ""
+}
+
+output "app_gateway_name" {
+  value = var.enable_app_gateway ? azurerm_application_gateway.webserver[0].name : ""
+}
+
+output "app_gateway_ip" {
+  value = var.enable_app_gateway ? azurerm_public_ip.appgw[0].ip_address : ""
+}
+
+output "nsg_diagnostic_setting_name" {
+  value = var.enable_nsg_diagnostics ? azurerm_monitor_diagnostic_setting.nsg[0].name : ""
+}
+
+output "nsg_log_categories" {
+  value = var.enable_nsg_diagnostics ? local.nsg_log_categories : []
+}
+
+output "license_type" {
+  value = var.license_type
+}
+
+output "web_server_logs" {
+  value = local.web_server_logs
+}
+
+output "ephemeral_os_disk" {
+  value = var.ephemeral_os_disk
+}
+
+output "proximity_placement_group_id" {
+  value = var.enable_ppg ? azurerm_proximity_placement_group.webserver[0].id : ""
+}
+
+output "data_disks" {
+  value = [for attachment in azurerm_virtual_machine_data_disk_attachment.data : {
+    name    = azurerm_managed_disk.data[attachment.lun].name
+    lun     = attachment.lun
+    caching = attachment.caching
+  }]
+}
+
+output "disk_resource_group_name" {
+  value = local.disk_resource_group_name
+}
+
+output "web_server_workers" {
+  value = var.web_server_workers
+}
+
+output "dns_record_fqdn" {
+  value = var.dns_zone_name != "" ? trimsuffix(azurerm_dns_a_record.webserver[0].fqdn, ".") : ""
 }
@@ -1,12 +1,12 @@
 # Configure the Terraform runtime requirements.
 terraform {
-  required_version = ">= 1.1.0"
+  required_version = ">= 1.5.0, < 2.0.0"
 
   required_providers {
     # Azure Resource Manager provider and version
     azurerm = {
       source  = "hashicorp/azurerm"
-      version = "~> 3.0.2"
+      version = "~> 3.82"
     }
     cloudinit = {
       source  = "hashicorp/cloudinit"
@@ -1,13 +1,31 @@
 package test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/services/devtestlabs/mgmt/2018-09-15/dtl"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-09-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/gruntwork-io/terratest/modules/azure"
+	http_helper "github.com/gruntwork-io/terratest/modules/http-helper"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/ssh"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,31 +37,45 @@ var subscriptionID string = "Based on security concenrns, I removed my ID"
 
 // Shared variables for all tests
 var (
-	terraformOptions  *terraform.Options
-	vmName            string
-	resourceGroupName string
-	nicName           string
-	once              sync.Once
-	initialized       bool
+	terraformOptions *terraform.Options
+	sharedOutputs    Outputs
+	once             sync.Once
+	initialized      bool
+	destroyed        bool
 )
 
-// setupTerraform initializes Terraform and applies the configuration once
+// setupTerraform initializes Terraform and applies the configuration once. With ISOLATE_RESOURCE_GROUPS
+// set, each test instead gets its own deployment, as setupIsolatedFixture describes.
 func setupTerraform(t *testing.T) {
+	requireAzureCredentials(t)
+	timeTest(t)
+	if envFlag("ISOLATE_RESOURCE_GROUPS") {
+		setupIsolatedFixture(t)
+		return
+	}
 	once.Do(func() {
 		terraformOptions = &terraform.Options{
 			TerraformDir: "../",
-			Vars: map[string]interface{}{
-				"labelPrefix": "lian0138",
-			},
+			Vars: moduleVars(t, map[string]interface{}{
+				"labelPrefix": defaultLabelPrefix,
+			}, nil),
 		}
 
-		// Run `terraform init` and `terraform apply`
-		terraform.InitAndApply(t, terraformOptions)
+		// Seed external dependencies, then run `terraform init` and `terraform apply`
+		require.NoError(t, runPreApplyHooksE(t, terraformOptions), "Pre-apply hooks failed")
+		checkVCPUQuota(t, subscriptionID, optionsRegion(terraformOptions), optionsVMSize(terraformOptions))
+		timeit("init", func() { terraform.Init(t, terraformOptions) })
+		var output string
+		var err error
+		timeit("apply", func() { output, err = terraform.ApplyE(t, terraformOptions) })
+		_, saveErr := saveApplyOutputE(t.Name(), terraformOptions.TerraformDir, output, err)
+		require.NoError(t, saveErr, "Failed to save apply output")
+		require.NoError(t, err, "Failed to apply the shared fixture")
+		_, err = saveStateJSONE(t, terraformOptions)
+		require.NoError(t, err, "Failed to save state JSON")
 
 		// Retrieve outputs
-		vmName = terraform.Output(t, terraformOptions, "vm_name")
-		resourceGroupName = terraform.Output(t, terraformOptions, "resource_group_name")
-		nicName = terraform.Output(t, terraformOptions, "nic_name")
+		sharedOutputs = loadOutputs(t, terraformOptions)
 
 		initialized = true
 	})
@@ -53,99 +85,2374 @@ func setupTerraform(t *testing.T) {
 	}
 }
 
-// cleanupTerraform destroys resources after all tests
-func cleanupTerraform() {
-	if initialized && terraformOptions != nil {
-		terraform.Destroy(&testing.T{}, terraformOptions)
+// setupIsolatedFixture deploys the fixture into a resource group of the test's own and points terraformOptions
+// and sharedOutputs at it until the test ends, when it is destroyed. This rules out interference between tests at the cost
+// of an apply per test.
+func setupIsolatedFixture(t *testing.T) {
+	options := &terraform.Options{
+		TerraformDir: copyModule(t),
+		Vars: moduleVars(t, map[string]interface{}{
+			"labelPrefix":           defaultLabelPrefix,
+			"resource_group_suffix": uniqueRGSuffix(t),
+		}, nil),
+	}
+
+	previousOptions, previousOutputs := terraformOptions, sharedOutputs
+	t.Cleanup(func() {
+		terraformOptions, sharedOutputs = previousOptions, previousOutputs
+		terraform.Destroy(t, options)
+		require.NoError(t, runPostDestroyHooksE(t, options), "Post-destroy hooks failed")
+	})
+	terraformOptions = options
+	checkNameCollision(t, subscriptionID, options)
+	checkVCPUQuota(t, subscriptionID, optionsRegion(options), optionsVMSize(options))
+	var err error
+	timeit("apply", func() { err = applyWithHooksE(t, options) })
+	require.NoError(t, err, "Failed to apply the isolated fixture")
+	sharedOutputs = loadOutputs(t, options)
+}
+
+// cleanupTerraform destroys resources after all tests, including a partial deployment whose setup
+// failed, and verifies nothing was left behind
+func cleanupTerraform() error {
+	if terraformOptions == nil {
+		return nil
+	}
+
+	// The fixture must destroy in one attempt; a retry means the module's dependency ordering is wrong.
+	// destroyed is set only once it succeeds, so a failed destroy is never taken for a finished one.
+	var problems []error
+	if !destroyed {
+		var err error
+		timeit("destroy", func() { err = cleanDestroyE(&testing.T{}, terraformOptions) })
+		if err != nil {
+			return fmt.Errorf("failed to destroy resources: %w", err)
+		}
+		destroyed = true
+
+		// An address still in state is a resource the provider couldn't delete or that drifted from state.
+		// It is reported with the rest rather than stopping cleanup, so the hooks and leak checks still run.
+		addresses, err := stateAddressesE(&testing.T{}, terraformOptions)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("failed to list the state after destroy: %w", err))
+		} else if len(addresses) > 0 {
+			problems = append(problems, fmt.Errorf("state still holds %d resources after destroy:\n%s",
+				len(addresses), strings.Join(addresses, "\n")))
+		}
+		if err := runPostDestroyHooksE(&testing.T{}, terraformOptions); err != nil {
+			return errors.Join(append(problems, err)...)
+		}
+	}
+
+	// Without outputs (setup failed or was interrupted) there are no names to verify against
+	if !initialized {
+		return errors.Join(problems...)
 	}
+	leaks := verifyTeardown(deployedResources{
+		SubscriptionID:    subscriptionID,
+		ResourceGroupName: sharedOutputs.ResourceGroupName,
+		VMName:            sharedOutputs.VMName,
+		PublicIPName:      sharedOutputs.PublicIPName,
+		NSGName:           sharedOutputs.NSGName,
+		VNetName:          sharedOutputs.VNetName,
+	})
+	return errors.Join(append(problems, leaks...)...)
 }
 
 func TestMain(m *testing.M) {
 	// Create a timestamped log file
-	timestamp := time.Now().Format("20060102_150405") // Format: YYYYMMDD_HHMMSS
-	logFileName := fmt.Sprintf("test_%s.log", timestamp)
-	logFile, err := os.Create(logFileName)
+	logFile, closeLog, err := setupLogging()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create log file: %v\n", err)
 		os.Exit(1)
 	}
-	defer logFile.Close()
 
 	// Redirect test output to the log file
 	originalStdout := os.Stdout
 	os.Stdout = logFile
-	defer func() {
+
+	// Clean up and exit if the run is interrupted (Ctrl-C) or terminated
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stopWatching := exitOnSignal(signals, func() {
+		teardownAfterRun(1, sharedOutputs.ResourceGroupName, cleanupTerraform)
 		os.Stdout = originalStdout
-		// Cleanup resources after all tests
-		cleanupTerraform()
-		logFile.Sync()
-	}()
+		closeLog()
+	}, os.Exit)
 
 	// Run tests and capture exit code
 	exitCode := m.Run()
+	stopWatching()
+	signal.Stop(signals)
+
+	// Cleanup resources after all tests, unless a failed run should be kept for debugging.
+	// os.Exit skips deferred calls, so this has to run explicitly before exiting.
+	exitCode = teardownAfterRun(exitCode, sharedOutputs.ResourceGroupName, cleanupTerraform)
+	if err := exportSpans(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export timing spans: %v\n", err)
+	}
+	os.Stdout = originalStdout
+	closeLog()
 
 	// Exit with the test result code
 	os.Exit(exitCode)
 }
 
 func TestAzureLinuxVMCreation(t *testing.T) {
-	terraformOptions := &terraform.Options{
-		// The path to where our Terraform code is located
-		TerraformDir: "../",
-		// Override the default terraform variables
-		Vars: map[string]interface{}{
-			"labelPrefix": "lian0138",
-		},
+	runModuleTest(t, nil, func(t *testing.T, out Outputs) {
+		// Confirm the VM and its NIC exist and are connected
+		assertDeployment(t, newDeployment(subscriptionID, out))
+	})
+}
+
+func TestNICExistsAndConnected(t *testing.T) {
+	// Setup Terraform resources
+	setupTerraform(t)
+
+	// Confirm NIC exists and is attached to the VM
+	assertNICExistsAndConnected(t, newDeployment(subscriptionID, sharedOutputs))
+}
+
+func TestNICCount(t *testing.T) {
+	// Setup Terraform resources
+	setupTerraform(t)
+
+	// Confirm the VM has the expected NICs and exactly one is primary
+	assertNICCount(t, newDeployment(subscriptionID, sharedOutputs))
+}
+
+func TestUbuntuVersion(t *testing.T) {
+	// Setup Terraform resources
+	setupTerraform(t)
+
+	// Retrieve VM details
+	vm, err := azureAPI.GetVirtualMachine(sharedOutputs.VMName, sharedOutputs.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+
+	// Confirm the VM runs the Ubuntu image the deployment asked for
+	imageRef := vm.StorageProfile.ImageReference
+	expectedOffer, expectedSku := optionsImage(terraformOptions)
+	assert.Equal(t, "Canonical", *imageRef.Publisher, "VM publisher is not Canonical")
+	assert.Equal(t, expectedOffer, *imageRef.Offer, "VM offer is not %s", expectedOffer)
+	assert.Equal(t, expectedSku, *imageRef.Sku, "VM SKU is not %s", expectedSku)
+}
+
+func TestVMExtension(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"install_monitor_agent": true,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		out := loadOutputs(t, options)
+		extensionName := out.MonitorAgentExtensionName
+
+		// Confirm the extension is installed and provisioned
+		extensions, err := getVirtualMachineExtensionsE(out.VMName, out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to list VM extensions")
+		installed := map[string]string{}
+		for _, extension := range extensions {
+			installed[to.String(extension.Name)] = to.String(extension.ProvisioningState)
+		}
+		require.Contains(t, installed, extensionName, "Extension is not installed on the VM")
+		assert.Equal(t, "Succeeded", installed[extensionName], "Extension %s is not in a Succeeded state", extensionName)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		// Confirm no extension is planned by default
+		plan := planIsolated(t, nil)
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_virtual_machine_extension.monitor_agent[0]", "Extension is planned although install_monitor_agent is false")
+	})
+}
+
+func TestClassroomDeploy(t *testing.T) {
+	prefixes := envList("CLASSROOM_PREFIXES")
+	if len(prefixes) == 0 {
+		t.Skip("Set CLASSROOM_PREFIXES to a comma-separated list of student prefixes to run the classroom deploy")
+	}
+	concurrency := envInt("CLASSROOM_CONCURRENCY", 5)
+
+	// Give every student their own copy of the module
+	studentOptions := map[string]*terraform.Options{}
+	for _, prefix := range prefixes {
+		studentOptions[prefix] = isolatedOptions(t, map[string]interface{}{
+			"labelPrefix": prefix,
+		})
+	}
+
+	// Destroy every deployment at the end, including partially applied ones
+	defer func() {
+		destroyErrs := forEachBounded(prefixes, concurrency, func(prefix string) error {
+			_, err := destroy(t, studentOptions[prefix])
+			return err
+		})
+		for prefix, err := range destroyErrs {
+			t.Errorf("Failed to destroy deployment for %s: %v", prefix, err)
+		}
+	}()
+
+	// Deploy and check each student without aborting the batch on failures
+	deployErrs := forEachBounded(prefixes, concurrency, func(prefix string) error {
+		options := studentOptions[prefix]
+		if _, err := terraform.InitAndApplyE(t, options); err != nil {
+			return fmt.Errorf("apply failed: %w", err)
+		}
+		out, err := loadOutputsE(t, options)
+		if err != nil {
+			return err
+		}
+		exists, err := azureAPI.VirtualMachineExists(out.VMName, out.ResourceGroupName, subscriptionID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("VM %s does not exist in %s", out.VMName, out.ResourceGroupName)
+		}
+		return nil
+	})
+
+	// Report a roll-up of every student's result
+	for _, prefix := range prefixes {
+		if err, failed := deployErrs[prefix]; failed {
+			t.Errorf("%s: FAILED: %v", prefix, err)
+		} else {
+			t.Logf("%s: OK", prefix)
+		}
+	}
+}
+
+func TestNSGRulePriorityOrdering(t *testing.T) {
+	setupTerraform(t)
+
+	rules, err := azure.GetAllNSGRulesE(sharedOutputs.ResourceGroupName, sharedOutputs.NSGName, subscriptionID)
+	require.NoError(t, err, "Failed to get NSG rules")
+	ruleSet := ""
+	for _, rule := range rules.SummarizedRules {
+		ruleSet += fmt.Sprintf("  %s %d %s\n", rule.Direction, rule.Priority, rule.Name)
 	}
 
-	defer terraform.Destroy(t, terraformOptions)
+	// Confirm every custom rule comes before Azure's defaults and none share a priority
+	assert.Empty(t, nsgPriorityConflicts(rules.SummarizedRules), "NSG %s has rule priority conflicts; rules are:\n%s", sharedOutputs.NSGName, ruleSet)
+}
 
-	// Run `terraform init` and `terraform apply`. Fail the test if there are any errors.
-	terraform.InitAndApply(t, terraformOptions)
+func TestNSGOutboundRules(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		setupTerraform(t)
+		assertEgressPolicy(t, sharedOutputs)
+	})
 
-	// Run `terraform output` to get the value of output variable
-	vmName := terraform.Output(t, terraformOptions, "vm_name")
-	resourceGroupName := terraform.Output(t, terraformOptions, "resource_group_name")
+	t.Run("Restricted", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"restrict_egress": true,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
 
-	// Confirm VM exists
-	assert.True(t, azure.VirtualMachineExists(t, vmName, resourceGroupName, subscriptionID))
+		assertEgressPolicy(t, loadOutputs(t, options))
+	})
 }
 
-func TestNICExistsAndConnected(t *testing.T) {
+// assertEgressPolicy checks the NSG's outbound rules match the deployment's restrict_egress setting
+func assertEgressPolicy(t *testing.T, out Outputs) {
+	rules, err := azure.GetAllNSGRulesE(out.ResourceGroupName, out.NSGName, subscriptionID)
+	require.NoError(t, err, "Failed to get NSG rules")
+
+	// Split the outbound rules into custom ones and Azure's defaults (priority 65000+)
+	outbound := []azure.NsgRuleSummary{}
+	custom := []azure.NsgRuleSummary{}
+	for _, rule := range rules.SummarizedRules {
+		if rule.Direction != "Outbound" {
+			continue
+		}
+		outbound = append(outbound, rule)
+		if rule.Priority < 65000 {
+			custom = append(custom, rule)
+		}
+	}
+	sort.Slice(outbound, func(i, j int) bool { return outbound[i].Priority < outbound[j].Priority })
+	ruleSet := ""
+	for _, rule := range outbound {
+		ruleSet += fmt.Sprintf("  %d %s %s %s:%s\n", rule.Priority, rule.Name, rule.Access, rule.DestinationAddressPrefix, rule.DestinationPortRange)
+	}
+
+	if !out.RestrictEgress {
+		// Confirm egress relies on Azure's default allow-internet-out
+		assert.Empty(t, custom, "Unrestricted egress should rely on default outbound rules:\n%s", ruleSet)
+		assert.Equal(t, "Allow", rules.FindRuleByName("AllowInternetOutBound").Access, "Default internet egress is not allowed:\n%s", ruleSet)
+		return
+	}
+
+	// Confirm a deny-all outbound rule exists with allow exceptions ahead of it
+	var denyAll *azure.NsgRuleSummary
+	for i, rule := range custom {
+		if rule.Access == "Deny" && rule.DestinationAddressPrefix == "*" && rule.DestinationPortRange == "*" {
+			denyAll = &custom[i]
+		}
+	}
+	require.NotNil(t, denyAll, "No deny-all outbound rule found:\n%s", ruleSet)
+	allowed := 0
+	for _, rule := range custom {
+		if rule.Access == "Allow" && rule.Priority < denyAll.Priority {
+			allowed++
+		}
+	}
+	assert.Positive(t, allowed, "No allow exceptions precede the deny-all outbound rule:\n%s", ruleSet)
+}
+
+func TestPlanGolden(t *testing.T) {
+	// Confirm the default configuration plans exactly the resources recorded in the golden file
+	assertGolden(t, "plan.golden.json", planChanges(t, isolatedOptions(t, nil)))
+}
+
+func TestInvalidLabelPrefixFails(t *testing.T) {
+	testCases := []struct {
+		name        string
+		labelPrefix string
+	}{
+		{"TooLong", "lian0138lian0138lian0138"},
+		{"InvalidCharacters", "lian_0138!"},
+		{"Uppercase", "Lian0138"},
+		{"LeadingDigit", "0138lian"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			options := isolatedOptions(t, map[string]interface{}{
+				"labelPrefix": testCase.labelPrefix,
+			})
+
+			// Confirm the plan is rejected by the module's validation, before anything is created
+			_, err := terraform.InitAndPlanE(t, options)
+			require.Error(t, err, "Plan succeeded with invalid labelPrefix %q", testCase.labelPrefix)
+			assert.Contains(t, err.Error(), "The labelPrefix must start with a lowercase letter", "Plan failed for a reason other than labelPrefix validation")
+		})
+	}
+}
+
+func TestBlankLabelPrefix(t *testing.T) {
+	testCases := []struct {
+		name        string
+		labelPrefix string
+	}{
+		{"Empty", ""},
+		{"Whitespace", "   "},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			options := isolatedOptions(t, map[string]interface{}{
+				"labelPrefix": testCase.labelPrefix,
+			})
+
+			// Confirm the plan is rejected with a clear message rather than planning names like "-A05-RG"
+			_, err := terraform.InitAndPlanE(t, options)
+			require.Error(t, err, "Plan succeeded with blank labelPrefix %q", testCase.labelPrefix)
+			assert.Contains(t, err.Error(), "The labelPrefix must not be empty or blank", "Plan failed for a reason other than the blank labelPrefix check")
+		})
+	}
+}
+
+func TestComputerNameLength(t *testing.T) {
+	// A 20-character prefix is the longest labelPrefix validation accepts; 10 characters plus "A05VM" fills
+	// a Windows name exactly
+	const maxPrefix = "lian0138lian0138lian"
+	const windowsBoundaryPrefix = "lian0138li"
+
+	testCases := []struct {
+		name        string
+		labelPrefix string
+		osType      string
+		expected    string
+	}{
+		{"LinuxMaxPrefix", maxPrefix, "linux", maxPrefix + "A05VM"},
+		{"WindowsBoundary", windowsBoundaryPrefix, "windows", windowsBoundaryPrefix + "A05VM"},
+		{"WindowsTruncated", maxPrefix, "windows", maxPrefix[:15]},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			plan := planIsolated(t, map[string]interface{}{
+				"labelPrefix":    testCase.labelPrefix,
+				"os_type":        testCase.osType,
+				"admin_password": "A05!pass-plan-only",
+			})
+			address := fmt.Sprintf("azurerm_%s_virtual_machine.webserver[0]", testCase.osType)
+			require.Contains(t, plan.ResourcePlannedValuesMap, address, "No %s VM planned", testCase.osType)
+
+			name, _ := plan.ResourcePlannedValuesMap[address].AttributeValues["computer_name"].(string)
+			t.Logf("Computer name %q is %d characters", name, len(name))
+
+			// Confirm the name fits the OS's limit and is cut the same way every time
+			assert.Equal(t, testCase.expected, name, "Computer name is %q (%d characters), expected %q", name, len(name), testCase.expected)
+		})
+	}
+
+	t.Run("PrefixTooLong", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"labelPrefix": maxPrefix + "x",
+		})
+
+		// Confirm a prefix one past the limit is rejected by validation rather than by the Azure API
+		_, err := terraform.InitAndPlanE(t, options)
+		require.Error(t, err, "Plan succeeded with a %d-character labelPrefix", len(maxPrefix)+1)
+		assert.Contains(t, err.Error(), "at most 20 characters", "Plan failed for a reason other than the labelPrefix length check")
+	})
+}
+
+func TestNameCollisionHandling(t *testing.T) {
+	options := isolatedOptions(t, nil)
+	resourceGroupName := moduleResourceGroupName(options.Vars)
+
+	// Take the module's resource group name before it deploys, as another user of a shared subscription might
+	require.NoError(t, createResourceGroupE(resourceGroupName, optionsRegion(options), subscriptionID), "Failed to pre-create resource group %s", resourceGroupName)
+	defer func() {
+		assert.NoError(t, deleteResourceGroupE(resourceGroupName, subscriptionID), "Failed to delete the pre-created resource group %s", resourceGroupName)
+	}()
+	defer terraform.Destroy(t, options)
+
+	t.Run("Preflight", func(t *testing.T) {
+		// Confirm the preflight names the colliding resource group before any apply
+		err := checkNameCollisionE(subscriptionID, resourceGroupName)
+		require.Error(t, err, "Preflight missed the existing resource group %s", resourceGroupName)
+		assert.Contains(t, err.Error(), resourceGroupName+" already exists")
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		// Confirm the apply fails on the resource group, naming it, rather than adopting it or failing later
+		output, err := terraform.InitAndApplyE(t, options)
+		require.Error(t, err, "Apply succeeded into the existing resource group %s", resourceGroupName)
+		assert.Contains(t, output, "already exists", "Apply failed for a reason other than the collision")
+		assert.Contains(t, output, resourceGroupName, "Apply error does not name the colliding resource group")
+
+		addresses, err := stateAddressesE(t, options)
+		require.NoError(t, err, "Failed to list state")
+		created := slices.DeleteFunc(addresses, func(address string) bool { return strings.HasPrefix(address, "data.") })
+		assert.Empty(t, created, "Apply created resources despite the collision")
+	})
+}
+
+func TestRemoteBackend(t *testing.T) {
+	backend, err := remoteBackendFromEnv()
+	if err != nil {
+		t.Skipf("Skipping: %v, naming an existing storage account container for remote state", err)
+	}
+	options, backend := remoteBackendOptions(t, nil, backend)
+	defer func() {
+		assert.NoError(t, deleteStateBlobE(backend, subscriptionID), "Failed to delete state blob %s", backend.Key)
+	}()
+	defer terraform.Destroy(t, options)
+
+	require.NoError(t, applyWithHooksE(t, options), "Failed to apply with the azurerm backend")
+
+	// Confirm the state was written to the storage account rather than a local file
+	exists, err := stateBlobExistsE(backend, subscriptionID)
+	require.NoError(t, err, "Failed to check the state blob")
+	assert.True(t, exists, "State blob %s is not in container %s of storage account %s", backend.Key, backend.ContainerName, backend.StorageAccountName)
+	assert.NoFileExists(t, filepath.Join(options.TerraformDir, "terraform.tfstate"), "Apply wrote local state despite the backend")
+
+	// Confirm outputs read back through the backend name the deployed resources
+	assertDeployment(t, newDeployment(subscriptionID, loadOutputs(t, options)))
+}
+
+func TestInvalidRegionFails(t *testing.T) {
+	options := isolatedOptions(t, map[string]interface{}{
+		"region": "nowhere-region",
+	})
+	defer terraform.Destroy(t, options)
+
+	// Confirm the apply is rejected at plan time with an error naming the bad region
+	_, err := terraform.InitAndApplyE(t, options)
+	require.Error(t, err, "Apply succeeded in region nowhere-region")
+	assert.Contains(t, err.Error(), `The region "nowhere-region" is not a known Azure region`, "Apply failed for a reason other than the region check")
+
+	// Confirm nothing was created
+	resourceGroupName := moduleResourceGroupName(options.Vars)
+	exists, err := azureAPI.ResourceGroupExists(resourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to check for resource group %s", resourceGroupName)
+	assert.False(t, exists, "Resource group %s was created despite the invalid region", resourceGroupName)
+}
+
+func TestBastionHost(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"enable_bastion": true,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+		out := loadOutputs(t, options)
+
+		// Confirm the bastion host is provisioned
+		bastion, err := getBastionHostE(out.BastionName, out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to get bastion host")
+		require.NotNil(t, bastion.BastionHostPropertiesFormat, "Bastion host has no properties")
+		assert.Equal(t, network.Succeeded, bastion.ProvisioningState, "Bastion host is not in a Succeeded state")
+
+		// Confirm the dedicated subnet has the /26 prefix Azure requires
+		subnet, err := azure.GetSubnetE("AzureBastionSubnet", out.VNetName, out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to get AzureBastionSubnet")
+		prefix := to.String(subnet.AddressPrefix)
+		_, subnetRange, err := net.ParseCIDR(prefix)
+		require.NoError(t, err, "AzureBastionSubnet has an invalid prefix %q", prefix)
+		ones, _ := subnetRange.Mask.Size()
+		assert.Equal(t, 26, ones, "AzureBastionSubnet prefix %s is not a /26", prefix)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		// Confirm no bastion is planned by default
+		plan := planIsolated(t, nil)
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_bastion_host.bastion[0]", "Bastion host is planned although enable_bastion is false")
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_subnet.bastion[0]", "AzureBastionSubnet is planned although enable_bastion is false")
+	})
+}
+
+func TestVMResources(t *testing.T) {
 	// Setup Terraform resources
 	setupTerraform(t)
 
-	// Confirm NIC exists
-	assert.True(t, azure.NetworkInterfaceExists(t, nicName, resourceGroupName, subscriptionID), "NIC does not exist")
+	spec, known := vmSizeSpecs[sharedOutputs.VMSize]
+	if !known {
+		t.Skipf("No CPU/memory spec recorded for VM size %s", sharedOutputs.VMSize)
+	}
+	host := sshHost(t, sharedOutputs)
+
+	// Confirm the guest sees the CPU count the size provides
+	nproc := strings.TrimSpace(runSSHCommand(t, host, "nproc"))
+	cpus, err := strconv.Atoi(nproc)
+	require.NoError(t, err, "Unexpected nproc output %q", nproc)
+	assert.Equal(t, spec.VCPUs, cpus, "Guest CPU count does not match VM size %s", sharedOutputs.VMSize)
+
+	// Confirm the guest memory is close to what the size provides
+	memKiB, err := parseMemTotalKiB(runSSHCommand(t, host, "cat /proc/meminfo"))
+	require.NoError(t, err, "Failed to read guest memory")
+	memGiB := float64(memKiB) / (1024 * 1024)
+	assert.True(t, memGiB <= spec.MemoryGiB && memGiB >= spec.MemoryGiB*(1-memoryTolerance),
+		"Guest memory %.2f GiB does not match the %.0f GiB of VM size %s", memGiB, spec.MemoryGiB, sharedOutputs.VMSize)
+}
+
+func TestOSDiskCaching(t *testing.T) {
+	t.Run("ReadWrite", func(t *testing.T) {
+		setupTerraform(t)
+		assertOSDiskCaching(t, sharedOutputs)
+	})
+
+	for _, caching := range []string{"ReadOnly", "None"} {
+		t.Run(caching, func(t *testing.T) {
+			options := isolatedOptions(t, map[string]interface{}{
+				"os_disk_caching": caching,
+			})
+			defer terraform.Destroy(t, options)
+			terraform.InitAndApply(t, options)
+
+			assertOSDiskCaching(t, loadOutputs(t, options))
+		})
+	}
+}
+
+// assertOSDiskCaching checks the VM's OS disk caching mode matches the deployment's os_disk_caching
+func assertOSDiskCaching(t *testing.T, out Outputs) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+
+	actual := string(vm.StorageProfile.OsDisk.Caching)
+	assert.Equal(t, out.OSDiskCaching, actual, "OS disk caching is %s, expected %s", actual, out.OSDiskCaching)
+}
+
+func TestEphemeralOSDisk(t *testing.T) {
+	t.Run("ManagedDefault", func(t *testing.T) {
+		setupTerraform(t)
+		assertEphemeralOSDisk(t, sharedOutputs)
+	})
+
+	t.Run("Ephemeral", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"ephemeral_os_disk": true,
+			"os_disk_caching":   "ReadOnly",
+			"vm_size":           "Standard_D2ds_v5",
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertEphemeralOSDisk(t, loadOutputs(t, options))
+	})
+
+	t.Run("IncompatibleSize", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"ephemeral_os_disk": true,
+			"os_disk_caching":   "ReadOnly",
+		})
+
+		// Confirm the default size, which has no room for the image on its temp disk, is rejected before anything is created
+		_, err := terraform.InitAndPlanE(t, options)
+		require.Error(t, err, "Plan succeeded with an ephemeral OS disk on an unsupported size")
+		assert.Contains(t, err.Error(), "An ephemeral OS disk needs os_disk_caching = ReadOnly")
+	})
+}
+
+// assertEphemeralOSDisk checks the VM's OS disk is ephemeral exactly when the deployment's ephemeral_os_disk is set
+func assertEphemeralOSDisk(t *testing.T, out Outputs) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+
+	settings := vm.StorageProfile.OsDisk.DiffDiskSettings
+	if !out.EphemeralOSDisk {
+		assert.Nil(t, settings, "OS disk has ephemeral settings %+v without ephemeral_os_disk", settings)
+		return
+	}
+	require.NotNil(t, settings, "OS disk has no ephemeral settings")
+	assert.Equal(t, "Local", string(settings.Option), "OS disk ephemeral option is %q, expected Local", settings.Option)
+}
+
+func TestDataDiskOrdering(t *testing.T) {
+	t.Run("None", func(t *testing.T) {
+		setupTerraform(t)
+		assertDataDisks(t, sharedOutputs, nil)
+	})
+
+	testCases := []struct {
+		name    string
+		caching []string
+	}{
+		{"One", []string{"ReadWrite"}},
+		{"Two", []string{"ReadOnly", "None"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			disks := []map[string]interface{}{}
+			for _, caching := range testCase.caching {
+				disks = append(disks, map[string]interface{}{"size_gb": 4, "caching": caching})
+			}
+			options := isolatedOptions(t, map[string]interface{}{
+				"data_disks": disks,
+			})
+			defer terraform.Destroy(t, options)
+			terraform.InitAndApply(t, options)
+
+			assertDataDisks(t, loadOutputs(t, options), testCase.caching)
+		})
+	}
+}
 
-	// Confirm NIC is attached to VM
-	vm, err := azure.GetVirtualMachineE(vmName, resourceGroupName, subscriptionID)
+// assertDataDisks checks the VM has one data disk per entry of caching, the nth at LUN n with the nth caching
+// and the name the data_disks output gives it
+func assertDataDisks(t *testing.T, out Outputs, caching []string) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
 	require.NoError(t, err, "Failed to get VM details")
-	if vm.NetworkProfile.NetworkInterfaces == nil {
-		t.Fatal("NetworkInterfaces is nil")
+	require.Len(t, out.DataDisks, len(caching), "data_disks output lists %d disks, expected %d", len(out.DataDisks), len(caching))
+
+	expected := map[int32]string{}
+	for i, disk := range out.DataDisks {
+		require.Equal(t, i, disk.LUN, "data_disks output lists %s at LUN %d, expected %d", disk.Name, disk.LUN, i)
+		expected[int32(i)] = fmt.Sprintf("%s (%s)", disk.Name, caching[i])
 	}
-	nicIDs := []string{}
-	for _, nicRef := range *vm.NetworkProfile.NetworkInterfaces {
-		if nicRef.ID != nil {
-			nicIDs = append(nicIDs, *nicRef.ID)
+	actual := map[int32]string{}
+	if vm.StorageProfile.DataDisks != nil {
+		for _, disk := range *vm.StorageProfile.DataDisks {
+			_, duplicate := actual[to.Int32(disk.Lun)]
+			assert.False(t, duplicate, "More than one data disk is attached at LUN %d", to.Int32(disk.Lun))
+			actual[to.Int32(disk.Lun)] = fmt.Sprintf("%s (%s)", to.String(disk.Name), disk.Caching)
 		}
 	}
-	expectedNICID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkInterfaces/%s", subscriptionID, resourceGroupName, nicName)
-	assert.Contains(t, nicIDs, expectedNICID, "NIC is not attached to VM")
+
+	// Confirm each LUN holds the expected disk with the expected caching
+	assert.Equal(t, expected, actual, "VM's LUN to disk mapping is %v, expected %v", actual, expected)
 }
 
-func TestUbuntuVersion(t *testing.T) {
+func TestDiskResourceGroup(t *testing.T) {
+	t.Run("SameRG", func(t *testing.T) {
+		setupTerraform(t)
+		assertDiskResourceGroup(t, sharedOutputs, sharedOutputs.ResourceGroupName)
+	})
+
+	t.Run("SplitRG", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"data_disks": []map[string]interface{}{{"size_gb": 4, "caching": "ReadWrite"}},
+		})
+		diskRG := fmt.Sprintf("%s-A05-Disks-RG", options.Vars["labelPrefix"])
+		options.Vars["disk_resource_group_name"] = diskRG
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertDiskResourceGroup(t, loadOutputs(t, options), diskRG)
+	})
+}
+
+// assertDiskResourceGroup checks the OS disk is in the VM's resource group and the data disks are in diskRG,
+// which the disk_resource_group_name output must also give
+func assertDiskResourceGroup(t *testing.T, out Outputs, diskRG string) {
+	assert.Equal(t, diskRG, out.DiskResourceGroupName, "disk_resource_group_name output is %s, expected %s", out.DiskResourceGroupName, diskRG)
+
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+	osDisk := vm.StorageProfile.OsDisk
+	require.NotNil(t, osDisk.ManagedDisk, "VM's OS disk is not a managed disk")
+
+	// Confirm the OS disk stays with the VM; Azure reports resource group names in IDs in any case
+	id, err := parseAzureResourceID(to.String(osDisk.ManagedDisk.ID))
+	require.NoError(t, err, "Failed to parse OS disk ID")
+	assert.True(t, strings.EqualFold(id.ResourceGroup, out.ResourceGroupName),
+		"OS disk %s is in resource group %s, expected %s", to.String(osDisk.Name), id.ResourceGroup, out.ResourceGroupName)
+
+	// Confirm every data disk is in the disk resource group
+	if vm.StorageProfile.DataDisks == nil {
+		return
+	}
+	for _, disk := range *vm.StorageProfile.DataDisks {
+		require.NotNil(t, disk.ManagedDisk, "Data disk %s is not a managed disk", to.String(disk.Name))
+		id, err := parseAzureResourceID(to.String(disk.ManagedDisk.ID))
+		require.NoError(t, err, "Failed to parse data disk ID")
+		assert.True(t, strings.EqualFold(id.ResourceGroup, diskRG),
+			"Data disk %s is in resource group %s, expected %s", to.String(disk.Name), id.ResourceGroup, diskRG)
+	}
+}
+
+func TestCustomDataMarker(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		setupTerraform(t)
+		assertCustomDataMarker(t, sharedOutputs)
+	})
+
+	t.Run("CustomFile", func(t *testing.T) {
+		marker := "/var/tmp/custom-data.done"
+		options := isolatedOptions(t, map[string]interface{}{
+			"custom_data_file":   "scripts/custom_init.sh",
+			"custom_data_marker": marker,
+		})
+
+		// The module reads custom_data_file relative to its own directory, so write the script into the copy
+		script := fmt.Sprintf("#!/bin/bash\ntouch %s\n", marker)
+		scriptDir := filepath.Join(options.TerraformDir, "scripts")
+		require.NoError(t, os.MkdirAll(scriptDir, 0o755), "Failed to create the script folder")
+		require.NoError(t, os.WriteFile(filepath.Join(scriptDir, "custom_init.sh"), []byte(script), 0o644), "Failed to write the custom data script")
+
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertCustomDataMarker(t, loadOutputs(t, options))
+	})
+}
+
+// assertCustomDataMarker checks the custom data script ran by looking for the marker file it creates.
+// runSSHCommand retries, which covers cloud-init still running when the VM first accepts SSH.
+func assertCustomDataMarker(t *testing.T, out Outputs) {
+	require.NotEmpty(t, out.CustomDataMarker, "No custom data marker output")
+	runSSHCommand(t, sshHost(t, out), fmt.Sprintf("test -f %q", out.CustomDataMarker))
+}
+
+func TestTrustedLaunch(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		setupTerraform(t)
+		assertTrustedLaunch(t, sharedOutputs)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"secure_boot_enabled": true,
+			"vtpm_enabled":        true,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertTrustedLaunch(t, loadOutputs(t, options))
+	})
+
+	t.Run("Gen1Image", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"secure_boot_enabled": true,
+			"image_sku":           "22_04-lts",
+		})
+
+		// Confirm the precondition rejects trusted launch on a Gen1 image before anything is created
+		_, err := terraform.InitAndPlanE(t, options)
+		require.Error(t, err, "Plan succeeded with trusted launch on a Gen1 image")
+		assert.Contains(t, err.Error(), "Trusted launch (secure_boot_enabled or vtpm_enabled) requires a Gen2 image", "Plan failed for a reason other than the Gen2 precondition")
+	})
+
+	t.Run("ExplicitGen2", func(t *testing.T) {
+		// Confirm an image declared Gen2 through hyper_v_generation passes, whatever its SKU is called
+		plan := planIsolated(t, map[string]interface{}{
+			"secure_boot_enabled": true,
+			"image_sku":           "22_04-lts",
+			"hyper_v_generation":  "V2",
+		})
+		assert.Contains(t, plan.ResourcePlannedValuesMap, "azurerm_linux_virtual_machine.webserver[0]")
+	})
+}
+
+// assertTrustedLaunch checks the VM's security type and UEFI settings match the deployment's trusted launch outputs
+func assertTrustedLaunch(t *testing.T, out Outputs) {
+	properties, err := getVirtualMachinePropertiesE(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM security profile")
+	profile := properties.SecurityProfile
+
+	if !out.SecureBootEnabled && !out.VTPMEnabled {
+		assert.NotEqual(t, "TrustedLaunch", profile.SecurityType, "VM uses trusted launch although it is disabled")
+		return
+	}
+	assert.Equal(t, "TrustedLaunch", profile.SecurityType, "VM security type is not TrustedLaunch")
+	assert.Equal(t, out.SecureBootEnabled, profile.UefiSettings.SecureBootEnabled, "Secure boot setting does not match")
+	assert.Equal(t, out.VTPMEnabled, profile.UefiSettings.VTpmEnabled, "vTPM setting does not match")
+}
+
+func TestCustomIndexContent(t *testing.T) {
 	// Setup Terraform resources
 	setupTerraform(t)
+	require.NotEmpty(t, sharedOutputs.IndexMarker, "No index marker output")
 
-	// Retrieve VM details
-	vm, err := azure.GetVirtualMachineE(vmName, resourceGroupName, subscriptionID)
-	require.NoError(t, err, "Failed to get VM details")
+	// Confirm the landing page is this deployment's, not the stock Apache page
+	body := waitForHTTP(t, webURL(sharedOutputs, "/"), bodyContains(sharedOutputs.IndexMarker))
+	assert.NotContains(t, body, "Apache2 Ubuntu Default Page", "The default Apache page is served")
+}
 
-	// Confirm Ubuntu version
-	imageRef := vm.StorageProfile.ImageReference
-	assert.Equal(t, "Canonical", *imageRef.Publisher, "VM publisher is not Canonical")
-	assert.Equal(t, "0001-com-ubuntu-server-jammy", *imageRef.Offer, "VM offer is not 0001-com-ubuntu-server-jammy")
-	expectedUbuntuSku := "22_04-lts-gen2" // Matches main.tf
-	assert.Equal(t, expectedUbuntuSku, *imageRef.Sku, "VM is not running Ubuntu 22.04 LTS Gen2")
+func TestNICInSelectedSubnet(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		setupTerraform(t)
+		assertNICInSelectedSubnet(t, sharedOutputs)
+	})
+
+	t.Run("MultiTier", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"subnets": map[string]string{
+				"web": "10.0.1.0/24",
+				"app": "10.0.3.0/24",
+				"db":  "10.0.4.0/24",
+			},
+			"nic_subnet_name": "app",
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertNICInSelectedSubnet(t, loadOutputs(t, options))
+	})
+}
+
+// assertNICInSelectedSubnet checks the NIC's IP configuration is in the subnet named by nic_subnet_name
+func assertNICInSelectedSubnet(t *testing.T, out Outputs) {
+	expectedID, ok := out.SubnetIDs[out.NICSubnetName]
+	require.True(t, ok, "Selected subnet %s is not among the subnet outputs", out.NICSubnetName)
+	expected, err := parseAzureResourceID(expectedID)
+	require.NoError(t, err, "Subnet output has a malformed ID")
+
+	nic, err := azureAPI.GetNetworkInterface(out.NICName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get NIC details")
+	require.NotNil(t, nic.IPConfigurations, "NIC has no IP configurations")
+
+	for _, ipConfig := range *nic.IPConfigurations {
+		require.True(t, ipConfig.InterfaceIPConfigurationPropertiesFormat != nil && ipConfig.Subnet != nil,
+			"NIC IP configuration %s has no subnet", to.String(ipConfig.Name))
+		actual, err := parseAzureResourceID(to.String(ipConfig.Subnet.ID))
+		require.NoError(t, err, "NIC references a malformed subnet ID")
+		assert.True(t, expected.Equal(actual), "NIC IP configuration %s is in subnet %s, expected %s",
+			to.String(ipConfig.Name), actual, expected)
+	}
+}
+
+func TestNICSecondaryIP(t *testing.T) {
+	t.Run("SingleDefault", func(t *testing.T) {
+		setupTerraform(t)
+		assertNICIPConfigurations(t, sharedOutputs, "")
+	})
+
+	for _, testCase := range []struct{ name, secondary string }{
+		{"Dynamic", "Dynamic"},
+		{"Static", "10.0.1.10"},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			options := isolatedOptions(t, map[string]interface{}{
+				"secondary_private_ip": testCase.secondary,
+			})
+			defer terraform.Destroy(t, options)
+			terraform.InitAndApply(t, options)
+
+			assertNICIPConfigurations(t, loadOutputs(t, options), testCase.secondary)
+		})
+	}
+}
+
+// assertNICIPConfigurations checks the NIC has one primary IP configuration plus, when secondary is set, a
+// second one that is Dynamic or holds the static address secondary names
+func assertNICIPConfigurations(t *testing.T, out Outputs, secondary string) {
+	nic, err := azureAPI.GetNetworkInterface(out.NICName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get NIC details")
+	require.NotNil(t, nic.IPConfigurations, "NIC has no IP configurations")
+	configs := *nic.IPConfigurations
+
+	summary := []string{}
+	primaries := 0
+	var secondaryConfig *network.InterfaceIPConfiguration
+	for i, config := range configs {
+		props := config.InterfaceIPConfigurationPropertiesFormat
+		require.NotNil(t, props, "NIC IP configuration %s has no properties", to.String(config.Name))
+		summary = append(summary, fmt.Sprintf("%s primary=%t %s %s", to.String(config.Name), to.Bool(props.Primary), props.PrivateIPAllocationMethod, to.String(props.PrivateIPAddress)))
+		if to.Bool(props.Primary) {
+			primaries++
+		} else {
+			secondaryConfig = &configs[i]
+		}
+	}
+
+	configList := strings.Join(summary, "\n")
+	expected := 1
+	if secondary != "" {
+		expected = 2
+	}
+	require.Len(t, configs, expected, "NIC has unexpected IP configurations:\n%s", configList)
+	assert.Equal(t, 1, primaries, "NIC does not have exactly one primary IP configuration:\n%s", configList)
+	if secondary == "" {
+		return
+	}
+
+	// Confirm the secondary configuration has the requested allocation and the address the output reports
+	require.NotNil(t, secondaryConfig, "NIC has no secondary IP configuration:\n%s", configList)
+	props := secondaryConfig.InterfaceIPConfigurationPropertiesFormat
+	actualAddress := to.String(props.PrivateIPAddress)
+	expectedAllocation, expectedAddress := "Static", secondary
+	if secondary == "Dynamic" {
+		expectedAllocation, expectedAddress = "Dynamic", out.SecondaryPrivateIP
+	}
+	assert.Equal(t, expectedAllocation, string(props.PrivateIPAllocationMethod), "Secondary IP configuration has the wrong allocation:\n%s", configList)
+	assert.Equal(t, expectedAddress, actualAddress, "Secondary IP configuration has the wrong address:\n%s", configList)
+	assert.Equal(t, out.SecondaryPrivateIP, actualAddress, "secondary_private_ip output does not match the NIC:\n%s", configList)
+}
+
+func TestMarketplaceTerms(t *testing.T) {
+	t.Run("NoPlan", func(t *testing.T) {
+		setupTerraform(t)
+		assertMarketplacePlan(t, sharedOutputs)
+	})
+
+	t.Run("Plan", func(t *testing.T) {
+		value := os.Getenv("MARKETPLACE_PLAN")
+		if value == "" {
+			t.Skip("Set MARKETPLACE_PLAN to publisher:product:name to deploy a marketplace image")
+		}
+		plan, err := parseMarketplacePlan(value)
+		require.NoError(t, err, "Invalid MARKETPLACE_PLAN")
+
+		// Check the terms before apply, where an unaccepted plan only fails after minutes with a vague error
+		options := isolatedOptions(t, map[string]interface{}{
+			"plan_name":      plan.Name,
+			"plan_publisher": plan.Publisher,
+			"plan_product":   plan.Product,
+		})
+		require.NoError(t, ensureMarketplaceTermsE(plan, subscriptionID, envFlag("ACCEPT_MARKETPLACE_TERMS")))
+
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertMarketplacePlan(t, loadOutputs(t, options))
+	})
+}
+
+// assertMarketplacePlan checks the VM's plan matches the deployment's plan outputs, or is absent without one
+func assertMarketplacePlan(t *testing.T, out Outputs) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+
+	if out.PlanName == "" {
+		assert.Nil(t, vm.Plan, "VM has a marketplace plan although none is configured")
+		return
+	}
+	require.NotNil(t, vm.Plan, "VM has no marketplace plan")
+	assert.Equal(t, out.PlanName, to.String(vm.Plan.Name), "VM plan name does not match")
+	assert.Equal(t, out.PlanPublisher, to.String(vm.Plan.Publisher), "VM plan publisher does not match")
+	assert.Equal(t, out.PlanProduct, to.String(vm.Plan.Product), "VM plan product does not match")
+}
+
+func TestDiskControllerType(t *testing.T) {
+	t.Run("SCSI", func(t *testing.T) {
+		setupTerraform(t)
+
+		properties, err := getVirtualMachinePropertiesE(sharedOutputs.VMName, sharedOutputs.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to get VM storage profile")
+
+		// Confirm the VM uses the configured disk controller
+		actual := properties.StorageProfile.DiskControllerType
+		assert.Equal(t, sharedOutputs.DiskControllerType, actual, "VM disk controller is %s, expected %s", actual, sharedOutputs.DiskControllerType)
+	})
+
+	t.Run("NVMeUnsupportedSize", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"disk_controller_type": "NVMe",
+			"vm_size":              "Standard_B1s",
+		})
+
+		// Confirm the precondition rejects NVMe on a size without it before anything is created
+		_, err := terraform.InitAndPlanE(t, options)
+		require.Error(t, err, "Plan succeeded with NVMe on Standard_B1s")
+		assert.Contains(t, err.Error(), "The NVMe disk controller requires", "Plan failed for a reason other than the NVMe precondition")
+	})
+
+	t.Run("NVMeExplicitGen2", func(t *testing.T) {
+		// Confirm an image declared Gen2 through hyper_v_generation can use NVMe, whatever its SKU is called
+		plan := planIsolated(t, map[string]interface{}{
+			"disk_controller_type": "NVMe",
+			"vm_size":              "Standard_D2s_v6",
+			"image_sku":            "22_04-lts",
+			"hyper_v_generation":   "V2",
+		})
+		assert.Contains(t, plan.ResourcePlannedValuesMap, "azurerm_linux_virtual_machine.webserver[0]")
+	})
+}
+
+func TestPrivateDNSLink(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"enable_private_dns": true,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		out := loadOutputs(t, options)
+
+		// Confirm the zone exists
+		exists, err := azure.PrivateDNSZoneExistsE(out.PrivateDNSZoneName, out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to check private DNS zone")
+		require.True(t, exists, "Private DNS zone %s does not exist", out.PrivateDNSZoneName)
+
+		// Confirm the VNet link is present, points at the VNet and registers VMs as configured
+		link, err := getPrivateDNSVirtualNetworkLinkE(out.PrivateDNSLinkName, out.PrivateDNSZoneName, out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to get the private DNS VNet link")
+		require.NotNil(t, link.VirtualNetworkLinkProperties, "VNet link has no properties")
+		assert.Equal(t, "Succeeded", string(link.ProvisioningState), "VNet link is in state %s/%s", link.ProvisioningState, link.VirtualNetworkLinkState)
+		require.NotNil(t, link.VirtualNetwork, "VNet link has no virtual network")
+		vnetID, err := parseAzureResourceID(to.String(link.VirtualNetwork.ID))
+		require.NoError(t, err, "VNet link references a malformed VNet ID")
+		assert.True(t, strings.EqualFold(out.VNetName, vnetID.Name), "VNet link points at %s, expected %s", vnetID.Name, out.VNetName)
+		assert.Equal(t, out.PrivateDNSAutoRegistration, to.Bool(link.RegistrationEnabled), "VNet link auto-registration does not match")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		// Confirm no zone or link is planned by default
+		plan := planIsolated(t, nil)
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_private_dns_zone.internal[0]", "Private DNS zone is planned although enable_private_dns is false")
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_private_dns_zone_virtual_network_link.internal[0]", "VNet link is planned although enable_private_dns is false")
+	})
+}
+
+func TestVMRebootRecovery(t *testing.T) {
+	requireFullProfile(t)
+	setupTerraform(t)
+
+	host := sshHost(t, sharedOutputs)
+	url := webURL(sharedOutputs, "/")
+	waitForHTTP(t, url, bodyContains(sharedOutputs.IndexMarker))
+
+	// Confirm the web server is set to start on boot
+	enabled := strings.TrimSpace(runSSHCommand(t, host, "systemctl is-enabled apache2"))
+	assert.Equal(t, "enabled", enabled, "apache2 is not enabled to start on boot")
+
+	// Confirm SSH and HTTP recover after a reboot
+	start := time.Now()
+	rebootAndWait(t, host)
+	waitForHTTP(t, url, bodyContains(sharedOutputs.IndexMarker))
+	recordSpan(span{Name: "reboot recovery", Start: start, Duration: time.Since(start)})
+}
+
+// dpkgLockCheck prints which of the package manager's locks a process holds, or nothing when all are free
+const dpkgLockCheck = "for lock in /var/lib/dpkg/lock /var/lib/dpkg/lock-frontend /var/lib/apt/lists/lock; do sudo fuser -s $lock && echo $lock; done; true"
+
+func TestWebServerAfterUpgrade(t *testing.T) {
+	requireFullProfile(t)
+	setupTerraform(t)
+
+	host := sshHost(t, sharedOutputs)
+	marker := sharedOutputs.CustomDataMarker
+	runSSHCommand(t, host, "true")
+
+	// Confirm the bootstrap script finished and cloud-init reached its end state
+	ok := assertEventually(t, testTimeouts.HTTP, testTimeouts.RetryInterval, func() (bool, string) {
+		_, err := ssh.CheckSshCommandE(t, host, "test -f "+marker)
+		return err == nil, fmt.Sprintf("%s does not exist yet", marker)
+	}, "The bootstrap never wrote its completion marker %s", marker)
+	if !ok {
+		t.FailNow()
+	}
+	status := runSSHCommand(t, host, "cloud-init status --wait || true")
+	assert.Contains(t, status, "status: done", "cloud-init did not finish cleanly: %s", strings.TrimSpace(status))
+
+	// Confirm no package install or unattended upgrade still holds the dpkg locks, and none was left half done
+	ok = assertEventually(t, testTimeouts.HTTP, testTimeouts.RetryInterval, func() (bool, string) {
+		held, err := ssh.CheckSshCommandE(t, host, dpkgLockCheck)
+		if err != nil {
+			return false, err.Error()
+		}
+		held = strings.TrimSpace(held)
+		return held == "", "still held: " + strings.ReplaceAll(held, "\n", ", ")
+	}, "The package manager never released its locks")
+	if !ok {
+		t.FailNow()
+	}
+	audit := strings.TrimSpace(runSSHCommand(t, host, "sudo dpkg --audit || true"))
+	assert.Empty(t, audit, "dpkg reports packages left unconfigured:\n%s", audit)
+
+	// Confirm the web server came through the package activity and still serves the site
+	active := strings.TrimSpace(runSSHCommand(t, host, "systemctl is-active apache2 || true"))
+	assert.Equal(t, "active", active, "apache2 is %s after the bootstrap", active)
+	waitForHTTP(t, webURL(sharedOutputs, "/"), bodyContains(sharedOutputs.IndexMarker))
+}
+
+func TestBootToReadySLO(t *testing.T) {
+	slo := time.Duration(envInt("BOOT_SLO_SECONDS", 180)) * time.Second
+
+	// A deployment of its own, as the shared fixture's web server may have been ready for a while
+	runModuleTest(t, nil, func(t *testing.T, out Outputs) {
+		// The clock starts once the outputs are read, a few seconds after apply; the first 200 is only seen
+		// at a poll, so the measurement can overshoot by up to RETRY_INTERVAL
+		applied := time.Now()
+		waitForHTTP(t, webURL(out, "/"), bodyContains(out.IndexMarker))
+		ready := time.Since(applied)
+		recordSpan(span{Name: "boot to ready", Start: applied, Duration: ready})
+		t.Logf("Web server was ready %s after apply (SLO %s)", ready.Round(time.Second), slo)
+
+		// Confirm cloud-init and the bootstrap script have the site up within the SLO
+		assert.LessOrEqual(t, ready, slo, "Web server took %s after apply to serve the page, over the %s SLO (BOOT_SLO_SECONDS)", ready.Round(time.Second), slo)
+	})
+}
+
+func TestWebServerLogs(t *testing.T) {
+	requireFullProfile(t)
+	setupTerraform(t)
+
+	host := sshHost(t, sharedOutputs)
+	logs := sharedOutputs.WebServerLogs
+	runSSHCommand(t, host, "true")
+
+	// Confirm the log files and their logrotate config are in place
+	for _, name := range []string{"access_log", "error_log", "logrotate_config"} {
+		path := logs[name]
+		require.NotEmpty(t, path, "No %s in the web_server_logs output", name)
+		_, err := ssh.CheckSshCommandE(t, host, "sudo test -f "+path)
+		assert.NoError(t, err, "The web server's %s %s does not exist", name, path)
+	}
+
+	// Confirm a request from the test is written to the access log
+	accessLogSize := func() (int, error) {
+		size, err := ssh.CheckSshCommandE(t, host, "sudo stat -c %s "+logs["access_log"])
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(strings.TrimSpace(size))
+	}
+	before, err := accessLogSize()
+	require.NoError(t, err, "Failed to read the size of %s", logs["access_log"])
+	waitForHTTP(t, webURL(sharedOutputs, "/"), bodyContains(sharedOutputs.IndexMarker))
+
+	ok := assertEventually(t, testTimeouts.HTTP, testTimeouts.RetryInterval, func() (bool, string) {
+		after, err := accessLogSize()
+		if err != nil {
+			return false, err.Error()
+		}
+		return after > before, fmt.Sprintf("%s is still %d bytes", logs["access_log"], after)
+	}, "The access log did not grow after a request")
+	if !ok {
+		t.FailNow()
+	}
+}
+
+func TestWebServerWorkers(t *testing.T) {
+	requireFullProfile(t)
+	setupTerraform(t)
+
+	host := sshHost(t, sharedOutputs)
+	expected := sharedOutputs.WebServerWorkers
+	waitForHTTP(t, webURL(sharedOutputs, "/"), bodyContains(sharedOutputs.IndexMarker))
+
+	// Confirm Apache loads the worker settings cloud-init wrote, with the expected limit
+	includes := runSSHCommand(t, host, "sudo apache2ctl -t -D DUMP_INCLUDES")
+	assert.Contains(t, includes, "a05-workers.conf", "Apache does not include the worker settings:\n%s", includes)
+	serverLimit := runSSHCommand(t, host, "awk '$1 == \"ServerLimit\" {print $2}' /etc/apache2/conf-enabled/a05-workers.conf")
+	assert.Equal(t, strconv.Itoa(expected), strings.TrimSpace(serverLimit), "Apache ServerLimit is %q, expected %d", strings.TrimSpace(serverLimit), expected)
+
+	// Confirm the running server has settled on that many worker processes
+	ok := assertEventually(t, testTimeouts.HTTP, testTimeouts.RetryInterval, func() (bool, string) {
+		count, err := ssh.CheckSshCommandE(t, host, "pgrep -c -P \"$(cat /var/run/apache2/apache2.pid)\" apache2")
+		if err != nil {
+			return false, err.Error()
+		}
+		return strings.TrimSpace(count) == strconv.Itoa(expected), fmt.Sprintf("Apache runs %s worker processes, expected %d", strings.TrimSpace(count), expected)
+	}, "Apache's worker process count does not match web_server_workers")
+	if !ok {
+		t.FailNow()
+	}
+}
+
+func TestSSHHostKeyStable(t *testing.T) {
+	requireFullProfile(t)
+	setupTerraform(t)
+
+	host := sshHost(t, sharedOutputs)
+	addr := net.JoinHostPort(sharedOutputs.PublicIP, "22")
+	runSSHCommand(t, host, "true")
+	before, err := hostKeyFingerprintE(addr, testTimeouts.SSH)
+	require.NoError(t, err, "Failed to read the SSH host key")
+
+	rebootAndWait(t, host)
+	after, err := hostKeyFingerprintE(addr, testTimeouts.SSH)
+	require.NoError(t, err, "Failed to read the SSH host key after reboot")
+
+	// Confirm the reboot did not regenerate the host key, which would break known_hosts pinning
+	assert.Equal(t, before, after, "SSH host key changed across reboot: %s before, %s after", before, after)
+}
+
+func TestHTTPRedirect(t *testing.T) {
+	t.Run("HTTPSEnabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"enable_https": true,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		out := loadOutputs(t, options)
+		require.True(t, out.HTTPSEnabled, "https_enabled output is false")
+
+		// Confirm HTTP answers with a redirect to the HTTPS site
+		status, location := waitForRedirect(t, webURL(out, "/"))
+		assert.Contains(t, []int{301, 302}, status, "HTTP answered %d instead of 301/302", status)
+		assert.True(t, strings.HasPrefix(location, "https://"), "Redirect Location %q does not point at https://", location)
+	})
+
+	t.Run("HTTPSDisabled", func(t *testing.T) {
+		setupTerraform(t)
+		if sharedOutputs.HTTPSEnabled {
+			t.Skip("HTTPS is enabled on the shared deployment")
+		}
+
+		// Confirm plain HTTP is served directly
+		waitForHTTP(t, webURL(sharedOutputs, "/"), bodyContains(sharedOutputs.IndexMarker))
+	})
+}
+
+func TestGzipCompression(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		setupTerraform(t)
+		if sharedOutputs.HTTPSEnabled {
+			t.Skip("HTTPS is enabled on the shared deployment, so HTTP only redirects")
+		}
+		assertGzipCompression(t, sharedOutputs)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"gzip_enabled": false,
+			"enable_https": false,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertGzipCompression(t, loadOutputs(t, options))
+	})
+}
+
+// assertGzipCompression checks the landing page is gzip-compressed on request exactly when the deployment's
+// gzip_enabled is set, and that the compressed page decodes to the uncompressed one
+func assertGzipCompression(t *testing.T, out Outputs) {
+	url := webURL(out, "/")
+	waitForHTTP(t, url, bodyContains(out.IndexMarker))
+
+	_, plain, err := getEncodedE(url, "identity")
+	require.NoError(t, err, "Failed to get the uncompressed page")
+	header, body, err := getEncodedE(url, "gzip")
+	require.NoError(t, err, "Failed to get the page with gzip accepted")
+	encoding := header.Get("Content-Encoding")
+
+	if !out.GzipEnabled {
+		// Confirm the page is sent as is
+		assert.Empty(t, encoding, "Page was sent with Content-Encoding %q although gzip is disabled; headers: %v", encoding, header)
+		assert.Equal(t, string(plain), string(body), "Page differs when gzip is accepted")
+		return
+	}
+
+	// Confirm the HTML page is compressed and decodes to the uncompressed page
+	require.Equal(t, "gzip", encoding, "Page was not gzip-compressed; headers: %v", header)
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err, "Page claims gzip but is not; headers: %v", header)
+	decoded, err := io.ReadAll(reader)
+	require.NoError(t, err, "Failed to decompress the page")
+	assert.Equal(t, string(plain), string(decoded), "Decompressed page differs from the uncompressed one")
+}
+
+func TestDeallocateAndStart(t *testing.T) {
+	requireFullProfile(t)
+	setupTerraform(t)
+	out := sharedOutputs
+	transitions := []string{}
+
+	// Leave the VM running for later tests even if this one fails part way. Deallocating releases a Dynamic
+	// public IP, so the outputs are refreshed to pick up the new address.
+	defer func() {
+		if state, err := getVMPowerStateE(out.VMName, out.ResourceGroupName, subscriptionID); err == nil && state != "running" {
+			assert.NoError(t, startVME(out.VMName, out.ResourceGroupName, subscriptionID), "Failed to restart the VM")
+		}
+		if out.PublicIPAllocation != "Static" {
+			runTerraformCommand(t, terraformOptions, terraform.FormatArgs(terraformOptions, "apply", "-refresh-only", "-auto-approve", "-input=false")...)
+			sharedOutputs = loadOutputs(t, terraformOptions)
+		}
+	}()
+
+	// Confirm the VM deallocates
+	require.NoError(t, deallocateVME(out.VMName, out.ResourceGroupName, subscriptionID), "Failed to deallocate the VM")
+	waitForPowerState(t, out, "deallocated", &transitions)
+
+	// Confirm it starts again
+	require.NoError(t, startVME(out.VMName, out.ResourceGroupName, subscriptionID), "Failed to start the VM")
+	waitForPowerState(t, out, "running", &transitions)
+	t.Logf("Observed power states: %s", strings.Join(transitions, " -> "))
+
+	// Confirm it serves the site again, at its current address
+	ip, err := azureAPI.GetPublicIPAddress(out.PublicIPName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get public IP details")
+	out.PublicIP = to.String(ip.IPAddress)
+	waitForHTTP(t, webURL(out, "/"), bodyContains(out.IndexMarker))
+}
+
+// waitForPowerState polls the VM's power state until it is want, for up to SSH_TIMEOUT, appending each state
+// it observes to transitions when it differs from the last one
+func waitForPowerState(t *testing.T, out Outputs, want string, transitions *[]string) {
+	ok := assertEventually(t, testTimeouts.SSH, testTimeouts.RetryInterval, func() (bool, string) {
+		state, err := getVMPowerStateE(out.VMName, out.ResourceGroupName, subscriptionID)
+		if err != nil {
+			return false, err.Error()
+		}
+		if len(*transitions) == 0 || (*transitions)[len(*transitions)-1] != state {
+			*transitions = append(*transitions, state)
+		}
+		return state == want, fmt.Sprintf("power state is %q", state)
+	}, "VM never reached power state %s", want)
+	if !ok {
+		t.Fatalf("Observed power states: %s", strings.Join(*transitions, " -> "))
+	}
+}
+
+func TestVMIDMatches(t *testing.T) {
+	// Setup Terraform resources
+	setupTerraform(t)
+
+	vm, err := azureAPI.GetVirtualMachine(sharedOutputs.VMName, sharedOutputs.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+
+	// Confirm the ID in state is the live VM's; Azure may return it with different casing
+	stateID, err := parseAzureResourceID(sharedOutputs.VMID)
+	require.NoError(t, err, "vm_id output is malformed")
+	liveID, err := parseAzureResourceID(to.String(vm.ID))
+	require.NoError(t, err, "VM has a malformed ID")
+	assert.True(t, stateID.Equal(liveID), "VM ID in state %s does not match the live VM ID %s", sharedOutputs.VMID, to.String(vm.ID))
+}
+
+func TestSubnetPrivateEndpointPolicies(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		setupTerraform(t)
+		assertPrivateEndpointPolicies(t, sharedOutputs)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"private_endpoint_policies_enabled": false,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertPrivateEndpointPolicies(t, loadOutputs(t, options))
+	})
+}
+
+// assertPrivateEndpointPolicies checks the NIC's subnet applies private endpoint network policies as configured
+func assertPrivateEndpointPolicies(t *testing.T, out Outputs) {
+	subnetID, err := parseAzureResourceID(out.SubnetIDs[out.NICSubnetName])
+	require.NoError(t, err, "Subnet output has a malformed ID")
+	vnetName, subnetName, _ := strings.Cut(subnetID.Name, "/")
+
+	subnet, err := azure.GetSubnetE(subnetName, vnetName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get subnet details")
+	require.NotNil(t, subnet.SubnetPropertiesFormat, "Subnet has no properties")
+
+	expected := "Disabled"
+	if out.PrivateEndpointPolicies {
+		expected = "Enabled"
+	}
+	actual := to.String(subnet.PrivateEndpointNetworkPolicies)
+	assert.Equal(t, expected, actual, "Subnet %s private endpoint network policies are %s, expected %s", subnetName, actual, expected)
+}
+
+func TestStateList(t *testing.T) {
+	// Setup Terraform resources
+	setupTerraform(t)
+
+	// Confirm state tracks the core resources, including the public IP when the deployment has one
+	addresses := stateAddresses(runTerraformCommand(t, terraformOptions, "state", "list"))
+	expectedAddresses := []string{
+		"azurerm_resource_group.rg",
+		"azurerm_virtual_network.vnet",
+		"azurerm_network_interface.webserver",
+		"azurerm_network_security_group.webserver",
+		"azurerm_linux_virtual_machine.webserver[0]",
+	}
+	if sharedOutputs.PublicIPEnabled {
+		expectedAddresses = append(expectedAddresses, "azurerm_public_ip.webserver[0]")
+	}
+	for _, expected := range expectedAddresses {
+		assert.Contains(t, addresses, expected, "%s is missing from terraform state", expected)
+	}
+}
+
+func TestHypervisorGeneration(t *testing.T) {
+	// Setup Terraform resources
+	setupTerraform(t)
+
+	vm, err := azureAPI.GetVirtualMachine(sharedOutputs.VMName, sharedOutputs.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+	require.NotNil(t, vm.StorageProfile.OsDisk.Name, "VM has no OS disk")
+
+	// The OS disk records the generation the VM was created with
+	disk, err := getDiskE(to.String(vm.StorageProfile.OsDisk.Name), sharedOutputs.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get OS disk details")
+	require.NotNil(t, disk.DiskProperties, "OS disk has no properties")
+
+	// Confirm the VM runs as the expected hypervisor generation
+	actual := string(disk.HyperVGeneration)
+	assert.Equal(t, sharedOutputs.HyperVGeneration, actual, "VM runs as hypervisor generation %s, expected %s", actual, sharedOutputs.HyperVGeneration)
+}
+
+func TestAcrossUbuntuVersions(t *testing.T) {
+	versions := []struct {
+		name  string
+		offer string
+		sku   string
+	}{
+		{name: "20.04", offer: "0001-com-ubuntu-server-focal", sku: "20_04-lts-gen2"},
+		{name: "22.04", offer: "0001-com-ubuntu-server-jammy", sku: "22_04-lts-gen2"},
+		{name: "24.04", offer: "ubuntu-24_04-lts", sku: "server"},
+	}
+
+	for _, version := range versions {
+		t.Run(version.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Every SKU here is Gen2, including 24.04's "server" which doesn't say so in its name
+			options := isolatedOptions(t, map[string]interface{}{
+				"image_offer":        version.offer,
+				"image_sku":          version.sku,
+				"hyper_v_generation": "V2",
+			})
+
+			region := optionsRegion(options)
+			available, err := imageAvailableE(region, "Canonical", version.offer, version.sku, subscriptionID)
+			require.NoError(t, err, "Failed to look up the Ubuntu %s image", version.name)
+			if !available {
+				t.Skipf("Ubuntu %s (%s/%s) is not available in %s", version.name, version.offer, version.sku, region)
+			}
+
+			defer terraform.Destroy(t, options)
+			terraform.InitAndApply(t, options)
+
+			// Confirm the VM booted, ran the bootstrap script and serves the index page
+			out := loadOutputs(t, options)
+			waitForHTTP(t, webURL(out, "/"), bodyContains(out.IndexMarker))
+		})
+	}
+}
+
+func TestDiskZoneAlignment(t *testing.T) {
+	t.Run("Regional", func(t *testing.T) {
+		setupTerraform(t)
+		assertDiskZoneAlignment(t, sharedOutputs)
+	})
+
+	t.Run("Zonal", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"zone": "1",
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertDiskZoneAlignment(t, loadOutputs(t, options))
+	})
+}
+
+// assertDiskZoneAlignment checks the VM and its OS disk are both in the deployment's zone, or both regional
+func assertDiskZoneAlignment(t *testing.T, out Outputs) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+	require.NotNil(t, vm.StorageProfile.OsDisk.Name, "VM has no OS disk")
+
+	disk, err := getDiskE(to.String(vm.StorageProfile.OsDisk.Name), out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get OS disk details")
+
+	expected := []string{}
+	if out.Zone != "" {
+		expected = []string{out.Zone}
+	}
+	vmZones, diskZones := to.StringSlice(vm.Zones), to.StringSlice(disk.Zones)
+
+	// Confirm the VM landed in the requested zone and the OS disk sits in the same one
+	assert.ElementsMatch(t, expected, vmZones, "VM zones %v do not match the zone output %q", vmZones, out.Zone)
+	assert.ElementsMatch(t, vmZones, diskZones, "OS disk zones %v do not match VM zones %v", diskZones, vmZones)
+}
+
+func TestPublicIPZoneAlignment(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		setupTerraform(t)
+		assertPublicIPZoneAlignment(t, sharedOutputs)
+	})
+
+	testCases := []struct {
+		name string
+		zone string
+	}{
+		{"Zonal", "1"},
+		{"ZoneRedundant", ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			options := isolatedOptions(t, map[string]interface{}{
+				"zone":                 testCase.zone,
+				"public_ip_sku":        "Standard",
+				"public_ip_allocation": "Static",
+			})
+			defer terraform.Destroy(t, options)
+			terraform.InitAndApply(t, options)
+
+			assertPublicIPZoneAlignment(t, loadOutputs(t, options))
+		})
+	}
+}
+
+// assertPublicIPZoneAlignment checks a Standard public IP is in the VM's zone, or in all three zones when the
+// VM is regional, and that a Basic IP has no zones
+func assertPublicIPZoneAlignment(t *testing.T, out Outputs) {
+	ip, err := azureAPI.GetPublicIPAddress(out.PublicIPName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get public IP details")
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+	ipZones, vmZones := to.StringSlice(ip.Zones), to.StringSlice(vm.Zones)
+
+	expected := []string{}
+	switch {
+	case out.PublicIPSKU != "Standard":
+	case len(vmZones) > 0:
+		expected = vmZones
+	default:
+		expected = []string{"1", "2", "3"}
+	}
+
+	// Confirm the IP's zones line up with the VM's
+	assert.ElementsMatch(t, expected, ipZones, "%s public IP zones %v, VM zones %v; expected IP zones %v", out.PublicIPSKU, ipZones, vmZones, expected)
+}
+
+func TestNoPublicIPWhenDisabled(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		setupTerraform(t)
+		assertPublicIPAttachment(t, sharedOutputs)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"public_ip_enabled": false,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertPublicIPAttachment(t, loadOutputs(t, options))
+	})
+}
+
+// assertPublicIPAttachment checks the NIC's IP config has a public IP exactly when the deployment enables one,
+// and that a private-only deployment created no public IP at all
+func assertPublicIPAttachment(t *testing.T, out Outputs) {
+	nic, err := azureAPI.GetNetworkInterface(out.NICName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get NIC details")
+	require.NotNil(t, nic.IPConfigurations, "NIC has no IP configurations")
+	require.NotEmpty(t, *nic.IPConfigurations, "NIC has no IP configurations")
+	ipConfig := (*nic.IPConfigurations)[0]
+
+	if out.PublicIPEnabled {
+		// Confirm the public IP is attached to the NIC
+		require.NotNil(t, ipConfig.PublicIPAddress, "NIC IP config has no public IP")
+		id, err := parseAzureResourceID(to.String(ipConfig.PublicIPAddress.ID))
+		require.NoError(t, err, "NIC references a malformed public IP ID")
+		expected := ResourceID{subscriptionID, out.ResourceGroupName, "Microsoft.Network", "publicIPAddresses", out.PublicIPName}
+		assert.True(t, id.Equal(expected), "NIC IP config references public IP %s, expected %s", id, expected)
+		return
+	}
+
+	// Confirm the NIC has no public IP and none was created in the resource group
+	assert.Nil(t, ipConfig.PublicIPAddress, "NIC IP config has a public IP although public_ip_enabled is false")
+	names, err := listPublicIPNamesE(out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to list public IPs")
+	assert.Empty(t, names, "Resource group has public IPs although public_ip_enabled is false")
+}
+
+func TestOutputStability(t *testing.T) {
+	// Setup Terraform resources
+	setupTerraform(t)
+
+	before, err := terraformOutputAllE(t, terraformOptions)
+	require.NoError(t, err, "Failed to read outputs after the first apply")
+
+	// A second apply with nothing changed should be a no-op
+	terraform.Apply(t, terraformOptions)
+	after, err := terraformOutputAllE(t, terraformOptions)
+	require.NoError(t, err, "Failed to read outputs after the second apply")
+
+	// Only a Static public IP is guaranteed to keep its address
+	ignore := []string{}
+	if sharedOutputs.PublicIPAllocation != "Static" {
+		ignore = append(ignore, "public_ip")
+	}
+
+	// Confirm no output changed, which would mean a resource was needlessly recreated
+	changed := changedOutputs(before, after, ignore...)
+	for _, name := range changed {
+		t.Logf("Output %s changed from %v to %v", name, before[name], after[name])
+	}
+	assert.Empty(t, changed, "Outputs changed after a no-op apply")
+}
+
+func TestCreationTimestampTag(t *testing.T) {
+	t.Run("StampedAtApply", func(t *testing.T) {
+		options := isolatedOptions(t, nil)
+		defer terraform.Destroy(t, options)
+		start := time.Now()
+		terraform.InitAndApply(t, options)
+		end := time.Now()
+
+		out := loadOutputs(t, options)
+		group, err := azureAPI.GetResourceGroup(out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to get resource group details")
+		vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to get VM details")
+
+		// Confirm both carry a created_at from this apply, allowing for clock skew
+		for resource, tags := range map[string]map[string]*string{"resource group": group.Tags, "VM": vm.Tags} {
+			createdAt, err := createdAtE(tags)
+			require.NoError(t, err, "The %s has no usable created_at tag", resource)
+			assert.WithinRange(t, createdAt, start.Add(-2*time.Minute), end.Add(2*time.Minute),
+				"The %s created_at tag %s is not from this apply", resource, createdAt.Format(time.RFC3339))
+		}
+	})
+
+	t.Run("Injected", func(t *testing.T) {
+		plan := planIsolated(t, map[string]interface{}{
+			"created_at": "2024-03-01T12:00:00Z",
+		})
+
+		// Confirm an injected timestamp is used as is
+		for _, address := range []string{"azurerm_resource_group.rg", "azurerm_linux_virtual_machine.webserver[0]"} {
+			require.Contains(t, plan.ResourcePlannedValuesMap, address)
+			tags := plan.ResourcePlannedValuesMap[address].AttributeValues["tags"]
+			assert.Equal(t, map[string]interface{}{"created_at": "2024-03-01T12:00:00Z"}, tags, "%s has unexpected tags", address)
+		}
+	})
+}
+
+func TestResourceTags(t *testing.T) {
+	setupTerraform(t)
+	tags := terraform.OutputMap(t, terraformOptions, "tags")
+	require.NotEmpty(t, tags, "The tags output is empty")
+
+	group, err := azureAPI.GetResourceGroup(sharedOutputs.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get resource group details")
+	vm, err := azureAPI.GetVirtualMachine(sharedOutputs.VMName, sharedOutputs.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+
+	// Confirm the tagged resources carry the module's tags; outside tooling may add its own
+	assertTagsSubset(t, group.Tags, tags, "Resource group is missing the module's tags")
+	assertTagsSubset(t, vm.Tags, tags, "VM is missing the module's tags")
+}
+
+func TestComplexOutputs(t *testing.T) {
+	// Setup Terraform resources
+	setupTerraform(t)
+
+	// Confirm a map output decodes with its values intact
+	tags := terraform.OutputMap(t, terraformOptions, "tags")
+	_, err := createdAtE(map[string]*string{createdAtTag: to.StringPtr(tags[createdAtTag])})
+	assert.NoError(t, err, "The tags output has no usable created_at: %v", tags)
+
+	// Confirm a populated list of objects decodes field by field, primary NIC first
+	nics := terraform.OutputListOfObjects(t, terraformOptions, "nics")
+	require.Len(t, nics, sharedOutputs.NICCount, "The nics output does not list every NIC: %v", nics)
+	assert.Equal(t, sharedOutputs.NICName, nics[0]["name"])
+	assert.Equal(t, true, nics[0]["primary"])
+	assert.NotEmpty(t, nics[0]["private_ip"], "The primary NIC has no private IP in the nics output")
+
+	// The same output decoded into a typed struct
+	var typed []struct {
+		Name      string `json:"name"`
+		PrivateIP string `json:"private_ip"`
+		Primary   bool   `json:"primary"`
+	}
+	terraform.OutputStruct(t, terraformOptions, "nics", &typed)
+	require.Len(t, typed, len(nics))
+	assert.Equal(t, nics[0]["private_ip"], typed[0].PrivateIP)
+
+	// Confirm a list that is empty for a single-NIC deployment decodes as empty rather than failing
+	secondary := terraform.OutputList(t, terraformOptions, "secondary_nic_names")
+	assert.Len(t, secondary, sharedOutputs.NICCount-1, "Unexpected secondary NICs: %v", secondary)
+}
+
+func TestRegionFailover(t *testing.T) {
+	requireFullProfile(t)
+
+	// westus3 is paired with eastus; override both for another pair
+	primaryRegion := envString("FAILOVER_PRIMARY_REGION", defaultRegion)
+	secondaryRegion := envString("FAILOVER_SECONDARY_REGION", "eastus")
+
+	primary := isolatedOptions(t, map[string]interface{}{"region": primaryRegion})
+	secondary := isolatedOptions(t, map[string]interface{}{"region": secondaryRegion})
+	primaryDestroyed := false
+	defer func() {
+		if !primaryDestroyed {
+			terraform.Destroy(t, primary)
+		}
+	}()
+	defer terraform.Destroy(t, secondary)
+
+	timeit("failover: primary apply in "+primaryRegion, func() { terraform.InitAndApply(t, primary) })
+	primaryOut := loadOutputs(t, primary)
+	timeit("failover: secondary apply in "+secondaryRegion, func() { terraform.InitAndApply(t, secondary) })
+	secondaryOut := loadOutputs(t, secondary)
+
+	// Confirm each deployment landed in its region, however Azure spells the name
+	assertOutputIn(t, primary, "location", regionNames(primaryRegion))
+	assertOutputIn(t, secondary, "location", regionNames(secondaryRegion))
+
+	// Confirm both regions serve the site
+	timeit("failover: both regions serving", func() {
+		waitForHTTP(t, webURL(primaryOut, "/"), bodyContains(primaryOut.IndexMarker))
+		waitForHTTP(t, webURL(secondaryOut, "/"), bodyContains(secondaryOut.IndexMarker))
+	})
+
+	// Confirm the secondary keeps serving once the primary is gone
+	timeit("failover: primary destroy", func() { terraform.Destroy(t, primary) })
+	primaryDestroyed = true
+	timeit("failover: secondary serving alone", func() {
+		waitForHTTP(t, webURL(secondaryOut, "/"), bodyContains(secondaryOut.IndexMarker))
+	})
+}
+
+func TestAutoShutdown(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"auto_shutdown_time": "1900",
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		out := loadOutputs(t, options)
+		require.NotEmpty(t, out.AutoShutdownScheduleName, "No auto-shutdown schedule name output")
+		schedule, err := getVMShutdownScheduleE(out.AutoShutdownScheduleName, out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to get the auto-shutdown schedule")
+		require.NotNil(t, schedule.ScheduleProperties, "Auto-shutdown schedule has no properties")
+
+		// Confirm the schedule is enabled, targets the VM and fires at the configured time
+		assert.Equal(t, dtl.EnableStatusEnabled, schedule.Status, "Auto-shutdown schedule is not enabled")
+		target, err := parseAzureResourceID(to.String(schedule.TargetResourceID))
+		require.NoError(t, err, "Auto-shutdown schedule has a malformed target")
+		assert.True(t, target.Equal(ResourceID{subscriptionID, out.ResourceGroupName, "Microsoft.Compute", "virtualMachines", out.VMName}),
+			"Auto-shutdown schedule targets %s instead of VM %s", target, out.VMName)
+		require.NotNil(t, schedule.DailyRecurrence, "Auto-shutdown schedule has no daily recurrence")
+		actual := to.String(schedule.DailyRecurrence.Time)
+		assert.Equal(t, out.AutoShutdownTime, actual, "VM shuts down at %s, expected %s", actual, out.AutoShutdownTime)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		// Confirm no schedule is planned by default
+		plan := planIsolated(t, nil)
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_dev_test_global_vm_shutdown_schedule.webserver[0]")
+	})
+}
+
+func TestHTTPProtocolCompat(t *testing.T) {
+	requireFullProfile(t)
+	setupTerraform(t)
+
+	waitForHTTP(t, webURL(sharedOutputs, "/"), bodyContains(sharedOutputs.IndexMarker))
+	addr := net.JoinHostPort(sharedOutputs.PublicIP, "80")
+
+	t.Run("HTTP10", func(t *testing.T) {
+		// Confirm a bare HTTP/1.0 request without a Host header gets a valid response
+		responses, err := rawHTTPE(addr, 30*time.Second, "GET / HTTP/1.0\r\n\r\n")
+		require.NoError(t, err, "HTTP/1.0 request failed")
+		assert.Equal(t, 200, responses[0].StatusCode, "Unexpected HTTP/1.0 response:\n%s", responses[0].Raw)
+		assert.Equal(t, 1, responses[0].ProtoMajor, "Not an HTTP/1.x response:\n%s", responses[0].Raw)
+	})
+
+	t.Run("KeepAlive", func(t *testing.T) {
+		request := fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\nConnection: keep-alive\r\n\r\n", sharedOutputs.PublicIP)
+
+		// Confirm the connection stays open for a second request after the first
+		responses, err := rawHTTPE(addr, 30*time.Second, request, request)
+		for _, response := range responses {
+			t.Logf("Response:\n%s", response.Raw)
+		}
+		require.NoError(t, err, "Keep-alive connection was not reusable")
+		assert.False(t, responses[0].Close, "Server did not honor keep-alive:\n%s", responses[0].Raw)
+		assert.Equal(t, 200, responses[1].StatusCode, "Unexpected response on the reused connection:\n%s", responses[1].Raw)
+	})
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	options := isolatedOptions(t, map[string]interface{}{
+		"enable_metrics": true,
+	})
+	defer terraform.Destroy(t, options)
+	terraform.InitAndApply(t, options)
+
+	out := loadOutputs(t, options)
+	require.True(t, out.MetricsEnabled, "metrics_enabled output is false")
+
+	// Confirm /metrics serves the Prometheus text format once the exporter is up
+	waitForHTTP(t, webURL(out, "/metrics"), prometheusText)
+}
+
+func TestSharedImageSource(t *testing.T) {
+	t.Run("Marketplace", func(t *testing.T) {
+		setupTerraform(t)
+		assertImageSource(t, sharedOutputs)
+	})
+
+	t.Run("SharedImage", func(t *testing.T) {
+		imageID := os.Getenv("SHARED_IMAGE_ID")
+		if imageID == "" {
+			t.Skip("Set SHARED_IMAGE_ID to a shared image gallery image ID to boot from a golden image")
+		}
+		options := isolatedOptions(t, map[string]interface{}{
+			"shared_image_id": imageID,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertImageSource(t, loadOutputs(t, options))
+	})
+}
+
+// assertImageSource checks the VM booted from the deployment's shared image, or from a marketplace image without one
+func assertImageSource(t *testing.T, out Outputs) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+	require.NotNil(t, vm.StorageProfile.ImageReference, "VM has no image reference")
+	ref := vm.StorageProfile.ImageReference
+	actual := fmt.Sprintf("id=%q publisher=%q offer=%q sku=%q", to.String(ref.ID), to.String(ref.Publisher), to.String(ref.Offer), to.String(ref.Sku))
+
+	if out.SharedImageID == "" {
+		// Confirm the image comes from the marketplace
+		assert.Empty(t, to.String(ref.ID), "VM boots from an image ID without shared_image_id: %s", actual)
+		assert.NotEmpty(t, to.String(ref.Publisher), "VM image has no marketplace publisher: %s", actual)
+		return
+	}
+
+	// Confirm the image is the gallery image and carries no marketplace fields
+	assert.True(t, strings.EqualFold(out.SharedImageID, to.String(ref.ID)), "VM image reference is %s, expected id %q", actual, out.SharedImageID)
+	assert.Empty(t, to.String(ref.Publisher), "VM image has a marketplace publisher: %s", actual)
+	assert.Empty(t, to.String(ref.Offer), "VM image has a marketplace offer: %s", actual)
+}
+
+func TestPublicIPNetworkSettings(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		setupTerraform(t)
+		assertPublicIPIdleTimeout(t, sharedOutputs)
+	})
+
+	t.Run("LongIdleTimeout", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"public_ip_idle_timeout": 30,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		assertPublicIPIdleTimeout(t, loadOutputs(t, options))
+	})
+}
+
+// assertPublicIPIdleTimeout checks the public IP drops idle connections after the deployment's configured timeout
+func assertPublicIPIdleTimeout(t *testing.T, out Outputs) {
+	ip, err := azureAPI.GetPublicIPAddress(out.PublicIPName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get public IP details")
+	require.NotNil(t, ip.PublicIPAddressPropertiesFormat, "Public IP has no properties")
+
+	actual := int(to.Int32(ip.IdleTimeoutInMinutes))
+	assert.Equal(t, out.PublicIPIdleTimeout, actual, "Public IP idle timeout is %d minutes, expected %d", actual, out.PublicIPIdleTimeout)
+}
+
+func TestLoadBalancer(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"enable_load_balancer": true,
+			"public_ip_enabled":    false,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		out := loadOutputs(t, options)
+		require.NotEmpty(t, out.LoadBalancerIP, "No load_balancer_ip output")
+		url := fmt.Sprintf("http://%s/", out.LoadBalancerIP)
+
+		// The frontend only answers once the health probe has marked the backend healthy
+		waitForHTTP(t, url, bodyContains(out.IndexMarker))
+
+		// Confirm requests through the frontend keep being served
+		for i := range 10 {
+			status, body, err := http_helper.HttpGetE(t, url, nil)
+			require.NoError(t, err, "Request %d through the load balancer failed", i+1)
+			assert.Equal(t, 200, status, "Request %d through the load balancer got %d: %.200q", i+1, status, body)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		// Confirm no load balancer is planned by default
+		plan := planIsolated(t, nil)
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_lb.webserver[0]")
+	})
+
+	t.Run("WithVMPublicIP", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"enable_load_balancer": true,
+		})
+
+		// Confirm the precondition rejects a Basic public IP on a backend before anything is created
+		_, err := terraform.InitAndPlanE(t, options)
+		require.Error(t, err, "Plan succeeded with a load balancer and a VM public IP")
+		assert.Contains(t, err.Error(), `The load balancer requires public_ip_enabled = false or public_ip_sku = "Standard"`, "Plan failed for a reason other than the public IP precondition")
+	})
+
+	t.Run("WithStandardVMPublicIP", func(t *testing.T) {
+		// Confirm a Standard public IP on the VM can sit behind the load balancer
+		plan := planIsolated(t, map[string]interface{}{
+			"enable_load_balancer": true,
+			"public_ip_sku":        "Standard",
+			"public_ip_allocation": "Static",
+		})
+		assert.Contains(t, plan.ResourcePlannedValuesMap, "azurerm_lb.webserver[0]")
+	})
+}
+
+func TestAppGateway(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		runModuleTest(t, map[string]interface{}{
+			"enable_app_gateway": true,
+		}, func(t *testing.T, out Outputs) {
+			require.NotEmpty(t, out.AppGatewayIP, "No app_gateway_ip output")
+
+			// On failure, log what the gateway's probe thinks of the backend before it is destroyed
+			defer func() {
+				if t.Failed() {
+					logAppGatewayBackendHealth(t, out)
+				}
+			}()
+
+			// Confirm the gateway forwards to the web server once its probe has found the backend
+			waitForHTTP(t, fmt.Sprintf("http://%s/", out.AppGatewayIP), bodyContains(out.IndexMarker))
+
+			// Confirm the gateway reports every backend server healthy
+			assertEventually(t, testTimeouts.HTTP, testTimeouts.RetryInterval, func() (bool, string) {
+				health, err := getAppGatewayBackendHealthE(out.AppGatewayName, out.ResourceGroupName, subscriptionID)
+				if err != nil {
+					return false, err.Error()
+				}
+				servers := backendServerHealth(*health)
+				if len(servers) == 0 {
+					return false, "no backend servers reported"
+				}
+				for _, state := range servers {
+					if state != network.Up {
+						return false, fmt.Sprintf("backend health %v", servers)
+					}
+				}
+				return true, ""
+			}, "Application gateway %s never reported its backend healthy", out.AppGatewayName)
+		})
+	})
+
+	t.Run("OverlappingSubnet", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"enable_app_gateway": true,
+			"subnets": map[string]string{
+				"web": "10.0.1.0/24",
+				"db":  "10.0.4.0/24",
+			},
+		})
+
+		// Confirm the precondition names the subnet the gateway's default prefix would overlap
+		_, err := terraform.InitAndPlanE(t, options)
+		require.Error(t, err, "Plan succeeded with the gateway subnet overlapping db")
+		assert.Contains(t, err.Error(), "The app_gateway_subnet_prefix 10.0.4.0/24 overlaps the subnets: db", "Plan failed for a reason other than the overlap precondition")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		// Confirm no application gateway is planned by default
+		plan := planIsolated(t, nil)
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_application_gateway.webserver[0]")
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_subnet.appgw[0]")
+	})
+}
+
+// logAppGatewayBackendHealth logs the health the application gateway's probe reports for each backend server
+func logAppGatewayBackendHealth(t *testing.T, out Outputs) {
+	health, err := getAppGatewayBackendHealthE(out.AppGatewayName, out.ResourceGroupName, subscriptionID)
+	if err != nil {
+		t.Logf("Failed to get application gateway backend health: %v", err)
+		return
+	}
+	t.Logf("Application gateway %s backend health: %v", out.AppGatewayName, backendServerHealth(*health))
+}
+
+func TestNSGDiagnostics(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"enable_nsg_diagnostics": true,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		out := loadOutputs(t, options)
+		require.NotEmpty(t, out.NSGDiagnosticSettingName, "No nsg_diagnostic_setting_name output")
+
+		// Confirm the NSG's diagnostic setting exists with every expected log category enabled
+		enabled, err := enabledLogCategoriesE(out.NSGDiagnosticSettingName, out.NSGID, subscriptionID)
+		require.NoError(t, err, "Failed to get the NSG diagnostic setting")
+		assert.ElementsMatch(t, out.NSGLogCategories, enabled, "NSG diagnostic setting enables %v, expected %v", enabled, out.NSGLogCategories)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		// Confirm no diagnostic setting is planned by default
+		plan := planIsolated(t, nil)
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_monitor_diagnostic_setting.nsg[0]")
+		assert.NotContains(t, plan.ResourcePlannedValuesMap, "azurerm_log_analytics_workspace.diagnostics[0]")
+	})
+}
+
+func TestLicenseType(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		setupTerraform(t)
+
+		// Confirm the VM is billed under the license type the deployment asked for
+		assertLicenseType(t, sharedOutputs)
+	})
+
+	t.Run("WrongPublisher", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"license_type": "RHEL_BYOS",
+		})
+
+		// Confirm a RHEL license on the Ubuntu image is rejected by the precondition before anything is created
+		_, err := terraform.InitAndPlanE(t, options)
+		require.Error(t, err, "Plan succeeded with a RHEL license type on an Ubuntu image")
+		assert.Contains(t, err.Error(), "The license_type RHEL_BYOS only applies to RedHat images")
+	})
+}
+
+// assertLicenseType checks the VM's license type against the license_type output, where "" means none was set
+func assertLicenseType(t *testing.T, out Outputs) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+
+	actual := to.String(vm.LicenseType)
+	assert.Equal(t, out.LicenseType, actual, "VM license type is %q, expected %q", actual, out.LicenseType)
+}
+
+func TestWindowsVM(t *testing.T) {
+	t.Run("Linux", func(t *testing.T) {
+		setupTerraform(t)
+		assertVMOS(t, sharedOutputs)
+	})
+
+	t.Run("Windows", func(t *testing.T) {
+		requireWindowsTests(t)
+		options := isolatedOptions(t, map[string]interface{}{
+			"os_type":        "windows",
+			"admin_password": fmt.Sprintf("A05!pass-%s", random.UniqueId()),
+			"vm_size":        "Standard_B2s",
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		out := loadOutputs(t, options)
+		require.Equal(t, "windows", out.OSType, "os_type output is %s", out.OSType)
+		assertVMOS(t, out)
+	})
+}
+
+func TestVMArchitecture(t *testing.T) {
+	t.Run("x64", func(t *testing.T) {
+		setupTerraform(t)
+		assertVMArchitecture(t, sharedOutputs)
+	})
+
+	t.Run("Arm64", func(t *testing.T) {
+		requireFullProfile(t)
+		runModuleTest(t, map[string]interface{}{
+			"architecture": "Arm64",
+			"vm_size":      "Standard_D2ps_v5",
+			"image_sku":    "22_04-lts-arm64",
+		}, assertVMArchitecture)
+	})
+
+	mismatches := []struct {
+		name     string
+		vars     map[string]interface{}
+		expected string
+	}{
+		{"Arm64SizeAsX64", map[string]interface{}{"vm_size": "Standard_D2ps_v5", "image_sku": "22_04-lts-arm64"}, "is an Arm64 size, but architecture is x64"},
+		{"X64SizeAsArm64", map[string]interface{}{"architecture": "Arm64", "image_sku": "22_04-lts-arm64"}, "is an x64 size, but architecture is Arm64"},
+		{"X64ImageOnArm64", map[string]interface{}{"architecture": "Arm64", "vm_size": "Standard_D2ps_v5"}, "is an x64 image, but architecture is Arm64"},
+	}
+	for _, testCase := range mismatches {
+		t.Run(testCase.name, func(t *testing.T) {
+			options := isolatedOptions(t, testCase.vars)
+
+			// Confirm the preconditions reject a size or image of the wrong architecture before anything is created
+			_, err := terraform.InitAndPlanE(t, options)
+			require.Error(t, err, "Plan succeeded with mismatched architecture %v", testCase.vars)
+			assert.Contains(t, err.Error(), testCase.expected, "Plan failed for a reason other than the architecture precondition")
+		})
+	}
+}
+
+// vmArchitectures maps the architecture variable to what uname -m reports on the VM
+var vmArchitectures = map[string]string{"x64": "x86_64", "Arm64": "aarch64"}
+
+// assertVMArchitecture checks the running kernel reports the deployment's architecture
+func assertVMArchitecture(t *testing.T, out Outputs) {
+	expected, ok := vmArchitectures[out.Architecture]
+	require.True(t, ok, "Unknown architecture output %q", out.Architecture)
+
+	machine := strings.TrimSpace(runSSHCommand(t, sshHost(t, out), "uname -m"))
+	assert.Equal(t, expected, machine, "VM reports architecture %s, expected %s for %s", machine, expected, out.Architecture)
+}
+
+// assertVMOS checks the VM runs the deployment's os_type from the matching image. Only the Windows variant
+// is checked for RDP, as the Linux VM has no RDP rule or listener.
+func assertVMOS(t *testing.T, out Outputs) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+	require.NotNil(t, vm.StorageProfile, "VM has no storage profile")
+	require.NotNil(t, vm.OsProfile, "VM has no OS profile")
+	osType := string(vm.StorageProfile.OsDisk.OsType)
+	publisher := ""
+	if vm.StorageProfile.ImageReference != nil {
+		publisher = to.String(vm.StorageProfile.ImageReference.Publisher)
+	}
+
+	if out.OSType != "windows" {
+		// Confirm the Linux VM boots a Linux image
+		assert.Equal(t, "Linux", osType, "VM OS disk is %s, expected Linux", osType)
+		assert.NotNil(t, vm.OsProfile.LinuxConfiguration, "Linux VM has no Linux configuration")
+		return
+	}
+
+	// Confirm the Windows VM boots the Windows Server image and accepts RDP
+	assert.Equal(t, "Windows", osType, "VM OS disk is %s, expected Windows", osType)
+	assert.Equal(t, "MicrosoftWindowsServer", publisher, "VM image publisher is %s, expected MicrosoftWindowsServer", publisher)
+	assert.NotNil(t, vm.OsProfile.WindowsConfiguration, "Windows VM has no Windows configuration")
+	rdp := net.JoinHostPort(out.PublicIP, "3389")
+	assertEventually(t, testTimeouts.SSH, testTimeouts.RetryInterval, func() (bool, string) {
+		conn, err := net.DialTimeout("tcp", rdp, 10*time.Second)
+		if err != nil {
+			return false, err.Error()
+		}
+		conn.Close()
+		return true, ""
+	}, "RDP at %s never accepted a connection", rdp)
+}
+
+func TestTagIdempotency(t *testing.T) {
+	setupTerraform(t)
+
+	// Re-plan the applied fixture; tags computed at plan time, like a timestamp() created_at without
+	// ignore_changes, would show up as an update on every run
+	options := *terraformOptions
+	options.PlanFilePath = filepath.Join(t.TempDir(), "tfplan")
+	plan := planStruct(t, &options)
+	pending := pendingTagChanges(&plan.RawPlan)
+
+	// Confirm a re-plan wants to change no tags
+	assert.Empty(t, pending, "Re-plan has pending tag changes:\n  %s", strings.Join(pending, "\n  "))
+}
+
+func TestDriftTolerance(t *testing.T) {
+	options := isolatedOptions(t, nil)
+	defer terraform.Destroy(t, options)
+	terraform.InitAndApply(t, options)
+	out := loadOutputs(t, options)
+	vmAddress := "azurerm_linux_virtual_machine.webserver[0]"
+
+	t.Run("ExternallyManagedTag", func(t *testing.T) {
+		require.NoError(t, setVMTagsE(out.VMName, out.ResourceGroupName, subscriptionID, map[string]*string{"managed_externally": to.StringPtr("cost-center-42")}), "Failed to tag the VM")
+		defer func() {
+			assert.NoError(t, setVMTagsE(out.VMName, out.ResourceGroupName, subscriptionID, map[string]*string{"managed_externally": nil}), "Failed to remove the injected tag")
+		}()
+
+		vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+		require.NoError(t, err, "Failed to get VM details")
+		if !assertTagsSubset(t, vm.Tags, map[string]string{"managed_externally": "cost-center-42"}, "The injected tag is not on the VM") {
+			t.FailNow()
+		}
+
+		// Confirm the plan leaves the outside tag alone
+		plan := planStruct(t, options)
+		require.Contains(t, plan.ResourceChangesMap, vmAddress)
+		assert.True(t, plan.ResourceChangesMap[vmAddress].Change.Actions.NoOp(), "Plan wants to %v the VM to revert managed_externally", plan.ResourceChangesMap[vmAddress].Change.Actions)
+	})
+
+	t.Run("UnmanagedTag", func(t *testing.T) {
+		require.NoError(t, setVMTagsE(out.VMName, out.ResourceGroupName, subscriptionID, map[string]*string{"owner": to.StringPtr("someone-else")}), "Failed to tag the VM")
+		defer func() {
+			assert.NoError(t, setVMTagsE(out.VMName, out.ResourceGroupName, subscriptionID, map[string]*string{"owner": nil}), "Failed to remove the injected tag")
+		}()
+
+		// Confirm a tag the module doesn't set, and doesn't ignore like managed_externally, is planned for removal
+		plan := planStruct(t, options)
+		require.Contains(t, plan.ResourceChangesMap, vmAddress)
+		assert.True(t, plan.ResourceChangesMap[vmAddress].Change.Actions.Update(), "Plan wants to %v the VM instead of removing the unmanaged owner tag", plan.ResourceChangesMap[vmAddress].Change.Actions)
+	})
+}
+
+func TestProximityPlacementGroup(t *testing.T) {
+	t.Run("Enabled", func(t *testing.T) {
+		options := isolatedOptions(t, map[string]interface{}{
+			"enable_ppg": true,
+		})
+		defer terraform.Destroy(t, options)
+		terraform.InitAndApply(t, options)
+
+		out := loadOutputs(t, options)
+		require.NotEmpty(t, out.ProximityPlacementGroupID, "No proximity_placement_group_id output")
+		assertProximityPlacementGroup(t, out)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		setupTerraform(t)
+		assertProximityPlacementGroup(t, sharedOutputs)
+	})
+}
+
+// assertProximityPlacementGroup checks the VM is in the deployment's proximity placement group, or in none
+// when the proximity_placement_group_id output is empty
+func assertProximityPlacementGroup(t *testing.T, out Outputs) {
+	vm, err := azureAPI.GetVirtualMachine(out.VMName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get VM details")
+
+	actual := ""
+	if vm.ProximityPlacementGroup != nil {
+		actual = to.String(vm.ProximityPlacementGroup.ID)
+	}
+	if out.ProximityPlacementGroupID == "" {
+		assert.Empty(t, actual, "VM is in proximity placement group %s without enable_ppg", actual)
+		return
+	}
+
+	// Confirm the VM references the module's group; Azure may change the casing of the ID
+	expected, err := parseAzureResourceID(out.ProximityPlacementGroupID)
+	require.NoError(t, err, "proximity_placement_group_id output is not a resource ID")
+	actualID, err := parseAzureResourceID(actual)
+	require.NoError(t, err, "VM's proximity placement group reference %q is not a resource ID", actual)
+	assert.True(t, expected.Equal(actualID), "VM is in proximity placement group %s, expected %s", actual, out.ProximityPlacementGroupID)
+}
+
+func TestTargetedApply(t *testing.T) {
+	options := isolatedOptions(t, nil)
+	defer terraform.Destroy(t, options)
+
+	// Stage 1: only the networking, which brings in the resource group it depends on
+	networking := *options
+	networking.Targets = []string{
+		"azurerm_virtual_network.vnet",
+		"azurerm_subnet.webserver",
+		"azurerm_network_security_group.webserver",
+	}
+	t.Logf("Stage 1 applying targets: %s", strings.Join(networking.Targets, ", "))
+	terraform.InitAndApply(t, &networking)
+
+	// Confirm the networking exists, named by the outputs that depend only on it
+	resourceGroupName := terraform.Output(t, options, "resource_group_name")
+	vnetName := terraform.Output(t, options, "vnet_name")
+	vnetExists, err := azureAPI.VirtualNetworkExists(vnetName, resourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to check the VNet")
+	assert.True(t, vnetExists, "VNet %s was not created by the targeted apply", vnetName)
+	nsgName := terraform.Output(t, options, "nsg_name")
+	nsgExists, err := azureAPI.NetworkSecurityGroupExists(nsgName, resourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to check the NSG")
+	assert.True(t, nsgExists, "NSG %s was not created by the targeted apply", nsgName)
+
+	// Confirm the VM was not created; its name output isn't set until it is, so check the state instead
+	addresses, err := stateAddressesE(t, options)
+	require.NoError(t, err, "Failed to list the state")
+	for _, address := range addresses {
+		assert.False(t, strings.HasPrefix(address, "azurerm_linux_virtual_machine.webserver"), "VM %s was created by an apply that did not target it", address)
+	}
+
+	// Stage 2: everything else
+	t.Log("Stage 2 applying all remaining resources")
+	terraform.Apply(t, options)
+
+	// Confirm the VM came up and serves the site
+	out := loadOutputs(t, options)
+	if !assertDeployment(t, newDeployment(subscriptionID, out)) {
+		t.FailNow()
+	}
+	waitForHTTP(t, webURL(out, "/"), bodyContains(out.IndexMarker))
+}
+
+func TestAsChildModule(t *testing.T) {
+	options := childModuleOptions(t, nil)
+	defer terraform.Destroy(t, options)
+	terraform.InitAndApply(t, options)
+
+	// Confirm the wrapper re-exports every output the module declares
+	declared, err := declaredOutputs(moduleDir)
+	require.NoError(t, err, "Failed to read the module's outputs")
+	all, err := terraformOutputAllE(t, options)
+	require.NoError(t, err, "Failed to read the wrapper's outputs")
+	for _, name := range declared {
+		assert.Contains(t, all, name, "Output %s is not re-exported through the child module", name)
+	}
+
+	// Confirm the VM exists and serves the site
+	out := loadOutputs(t, options)
+	if !assertDeployment(t, newDeployment(subscriptionID, out)) {
+		t.FailNow()
+	}
+	waitForHTTP(t, webURL(out, "/"), bodyContains(out.IndexMarker))
+}
+
+func TestDNSARecord(t *testing.T) {
+	zoneName, zoneResourceGroup := os.Getenv("DNS_ZONE_NAME"), os.Getenv("DNS_ZONE_RESOURCE_GROUP")
+	if zoneName == "" || zoneResourceGroup == "" {
+		t.Skip("Set DNS_ZONE_NAME and DNS_ZONE_RESOURCE_GROUP to an existing public DNS zone to test a custom domain")
+	}
+	options := isolatedOptions(t, map[string]interface{}{
+		"dns_zone_name":           zoneName,
+		"dns_zone_resource_group": zoneResourceGroup,
+	})
+	// The deployment's unique labelPrefix keeps parallel runs from sharing a record
+	recordName := options.Vars["labelPrefix"].(string)
+	options.Vars["dns_record_name"] = recordName
+	defer terraform.Destroy(t, options)
+	terraform.InitAndApply(t, options)
+
+	out := loadOutputs(t, options)
+	require.Equal(t, recordName+"."+zoneName, out.DNSRecordFQDN, "Unexpected dns_record_fqdn output")
+	publicIP, err := azureAPI.GetPublicIPAddress(out.PublicIPName, out.ResourceGroupName, subscriptionID)
+	require.NoError(t, err, "Failed to get public IP details")
+
+	// Confirm the record exists and aliases the VM's public IP
+	record, err := getDNSARecordE(recordName, zoneName, zoneResourceGroup, subscriptionID)
+	require.NoError(t, err, "Failed to get the A record")
+	require.NotNil(t, record.RecordSetProperties, "A record has no properties")
+	require.NotNil(t, record.TargetResource, "A record is not an alias of the public IP")
+	assert.True(t, strings.EqualFold(to.String(publicIP.ID), to.String(record.TargetResource.ID)),
+		"A record aliases %s, expected %s", to.String(record.TargetResource.ID), to.String(publicIP.ID))
+
+	// Confirm the name resolves to the VM's address once the record has propagated
+	ok := assertEventually(t, testTimeouts.HTTP, testTimeouts.RetryInterval, func() (bool, string) {
+		resolved, err := net.LookupHost(out.DNSRecordFQDN)
+		if err != nil {
+			return false, err.Error()
+		}
+		return slices.Contains(resolved, out.PublicIP), fmt.Sprintf("%s resolves to %v, expected %s", out.DNSRecordFQDN, resolved, out.PublicIP)
+	}, "The DNS record never resolved to the VM's public IP")
+	if !ok {
+		t.FailNow()
+	}
 }
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-09-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	"github.com/gruntwork-io/terratest/modules/azure"
+)
+
+// azureClient wraps the Azure lookups the tests and teardown checks make, so their assertion and
+// retry logic can run offline against a fake
+type azureClient interface {
+	ResourceGroupExists(resourceGroupName, subscriptionID string) (bool, error)
+	GetResourceGroup(resourceGroupName, subscriptionID string) (*resources.Group, error)
+	VirtualMachineExists(vmName, resourceGroupName, subscriptionID string) (bool, error)
+	GetVirtualMachine(vmName, resourceGroupName, subscriptionID string) (*compute.VirtualMachine, error)
+	NetworkInterfaceExists(nicName, resourceGroupName, subscriptionID string) (bool, error)
+	GetNetworkInterface(nicName, resourceGroupName, subscriptionID string) (*network.Interface, error)
+	PublicAddressExists(publicIPName, resourceGroupName, subscriptionID string) (bool, error)
+	GetPublicIPAddress(publicIPName, resourceGroupName, subscriptionID string) (*network.PublicIPAddress, error)
+	NetworkSecurityGroupExists(nsgName, resourceGroupName, subscriptionID string) (bool, error)
+	VirtualNetworkExists(vnetName, resourceGroupName, subscriptionID string) (bool, error)
+}
+
+// azureAPI is the client the suite calls Azure through; unit tests swap it for a fakeAzureClient
+var azureAPI azureClient = liveAzureClient{}
+
+// liveAzureClient calls Azure through terratest's azure module. The Get calls the tests poll are retried
+// when Azure throttles them.
+type liveAzureClient struct{}
+
+func (liveAzureClient) ResourceGroupExists(resourceGroupName, subscriptionID string) (bool, error) {
+	exists, err := azure.ResourceGroupExistsE(resourceGroupName, subscriptionID)
+	return exists, wrapAzureErr("check resource group "+resourceGroupName, subscriptionID, err)
+}
+
+func (liveAzureClient) GetResourceGroup(resourceGroupName, subscriptionID string) (*resources.Group, error) {
+	group, err := retryThrottledE(func() (*resources.Group, error) {
+		return azure.GetAResourceGroupE(resourceGroupName, subscriptionID)
+	})
+	return group, wrapAzureErr("get resource group "+resourceGroupName, subscriptionID, err)
+}
+
+func (liveAzureClient) VirtualMachineExists(vmName, resourceGroupName, subscriptionID string) (bool, error) {
+	exists, err := azure.VirtualMachineExistsE(vmName, resourceGroupName, subscriptionID)
+	return exists, wrapAzureErr(fmt.Sprintf("check VM %s in resource group %s", vmName, resourceGroupName), subscriptionID, err)
+}
+
+func (liveAzureClient) GetVirtualMachine(vmName, resourceGroupName, subscriptionID string) (*compute.VirtualMachine, error) {
+	vm, err := retryThrottledE(func() (*compute.VirtualMachine, error) {
+		return azure.GetVirtualMachineE(vmName, resourceGroupName, subscriptionID)
+	})
+	return vm, wrapAzureErr(fmt.Sprintf("get VM %s in resource group %s", vmName, resourceGroupName), subscriptionID, err)
+}
+
+func (liveAzureClient) NetworkInterfaceExists(nicName, resourceGroupName, subscriptionID string) (bool, error) {
+	exists, err := azure.NetworkInterfaceExistsE(nicName, resourceGroupName, subscriptionID)
+	return exists, wrapAzureErr(fmt.Sprintf("check NIC %s in resource group %s", nicName, resourceGroupName), subscriptionID, err)
+}
+
+func (liveAzureClient) GetNetworkInterface(nicName, resourceGroupName, subscriptionID string) (*network.Interface, error) {
+	nic, err := retryThrottledE(func() (*network.Interface, error) {
+		return azure.GetNetworkInterfaceE(nicName, resourceGroupName, subscriptionID)
+	})
+	return nic, wrapAzureErr(fmt.Sprintf("get NIC %s in resource group %s", nicName, resourceGroupName), subscriptionID, err)
+}
+
+func (liveAzureClient) PublicAddressExists(publicIPName, resourceGroupName, subscriptionID string) (bool, error) {
+	exists, err := azure.PublicAddressExistsE(publicIPName, resourceGroupName, subscriptionID)
+	return exists, wrapAzureErr(fmt.Sprintf("check public IP %s in resource group %s", publicIPName, resourceGroupName), subscriptionID, err)
+}
+
+func (liveAzureClient) GetPublicIPAddress(publicIPName, resourceGroupName, subscriptionID string) (*network.PublicIPAddress, error) {
+	ip, err := retryThrottledE(func() (*network.PublicIPAddress, error) {
+		return azure.GetPublicIPAddressE(publicIPName, resourceGroupName, subscriptionID)
+	})
+	return ip, wrapAzureErr(fmt.Sprintf("get public IP %s in resource group %s", publicIPName, resourceGroupName), subscriptionID, err)
+}
+
+func (liveAzureClient) NetworkSecurityGroupExists(nsgName, resourceGroupName, subscriptionID string) (bool, error) {
+	exists, err := nsgExistsE(nsgName, resourceGroupName, subscriptionID)
+	return exists, wrapAzureErr(fmt.Sprintf("check NSG %s in resource group %s", nsgName, resourceGroupName), subscriptionID, err)
+}
+
+func (liveAzureClient) VirtualNetworkExists(vnetName, resourceGroupName, subscriptionID string) (bool, error) {
+	exists, err := azure.VirtualNetworkExistsE(vnetName, resourceGroupName, subscriptionID)
+	return exists, wrapAzureErr(fmt.Sprintf("check virtual network %s in resource group %s", vnetName, resourceGroupName), subscriptionID, err)
+}
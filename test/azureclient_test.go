@@ -0,0 +1,121 @@
+package test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-09-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+)
+
+// fakeAzureClient returns canned responses in place of Azure
+type fakeAzureClient struct {
+	resourceGroupExists bool
+	resourceGroup       *resources.Group          // returned by GetResourceGroup
+	existing            []string                  // names of the resources that exist
+	vms                 []*compute.VirtualMachine // returned by GetVirtualMachine in turn, repeating the last
+	nics                map[string]*network.Interface
+	publicIPs           map[string]*network.PublicIPAddress
+	err                 error // returned by every call when set
+	vmCalls             int
+	scopes              []string // the subscription/resource group each call looked in
+}
+
+// useFakeAzure swaps in fake as the suite's Azure client for the duration of the test
+func useFakeAzure(t *testing.T, fake *fakeAzureClient) *fakeAzureClient {
+	original := azureAPI
+	azureAPI = fake
+	t.Cleanup(func() { azureAPI = original })
+	return fake
+}
+
+// lookIn records the subscription and resource group a call looked in
+func (f *fakeAzureClient) lookIn(resourceGroupName, subscriptionID string) {
+	f.scopes = append(f.scopes, subscriptionID+"/"+resourceGroupName)
+}
+
+func (f *fakeAzureClient) exists(name string) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	return slices.Contains(f.existing, name), nil
+}
+
+func (f *fakeAzureClient) ResourceGroupExists(resourceGroupName, subscriptionID string) (bool, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	return f.resourceGroupExists, f.err
+}
+
+func (f *fakeAzureClient) GetResourceGroup(resourceGroupName, subscriptionID string) (*resources.Group, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.resourceGroup == nil {
+		return nil, fmt.Errorf("resource group %s not found", resourceGroupName)
+	}
+	return f.resourceGroup, nil
+}
+
+func (f *fakeAzureClient) VirtualMachineExists(vmName, resourceGroupName, subscriptionID string) (bool, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	return f.exists(vmName)
+}
+
+func (f *fakeAzureClient) GetVirtualMachine(vmName, resourceGroupName, subscriptionID string) (*compute.VirtualMachine, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	f.vmCalls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if len(f.vms) == 0 {
+		return nil, fmt.Errorf("VM %s not found", vmName)
+	}
+	return f.vms[min(f.vmCalls, len(f.vms))-1], nil
+}
+
+func (f *fakeAzureClient) NetworkInterfaceExists(nicName, resourceGroupName, subscriptionID string) (bool, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	return f.exists(nicName)
+}
+
+func (f *fakeAzureClient) GetNetworkInterface(nicName, resourceGroupName, subscriptionID string) (*network.Interface, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	if f.err != nil {
+		return nil, f.err
+	}
+	nic, ok := f.nics[nicName]
+	if !ok {
+		return nil, fmt.Errorf("NIC %s not found", nicName)
+	}
+	return nic, nil
+}
+
+func (f *fakeAzureClient) PublicAddressExists(publicIPName, resourceGroupName, subscriptionID string) (bool, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	return f.exists(publicIPName)
+}
+
+func (f *fakeAzureClient) GetPublicIPAddress(publicIPName, resourceGroupName, subscriptionID string) (*network.PublicIPAddress, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	if f.err != nil {
+		return nil, f.err
+	}
+	ip, ok := f.publicIPs[publicIPName]
+	if !ok {
+		return nil, fmt.Errorf("public IP %s not found", publicIPName)
+	}
+	return ip, nil
+}
+
+func (f *fakeAzureClient) NetworkSecurityGroupExists(nsgName, resourceGroupName, subscriptionID string) (bool, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	return f.exists(nsgName)
+}
+
+func (f *fakeAzureClient) VirtualNetworkExists(vnetName, resourceGroupName, subscriptionID string) (bool, error) {
+	f.lookIn(resourceGroupName, subscriptionID)
+	return f.exists(vnetName)
+}
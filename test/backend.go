@@ -0,0 +1,110 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gruntwork-io/terratest/modules/azure"
+)
+
+// remoteBackendEnvVars locate the existing storage account container that remote state is kept in
+var remoteBackendEnvVars = []string{"BACKEND_RESOURCE_GROUP", "BACKEND_STORAGE_ACCOUNT", "BACKEND_CONTAINER"}
+
+// backendFile is the file declaring the azurerm backend in a copy of the module, which otherwise keeps local
+// state. The backend block is left empty for terraform init's -backend-config values to fill in.
+const backendFile = "backend.tf"
+
+// remoteBackend is where an azurerm backend keeps a deployment's state: the blob Key in a container
+type remoteBackend struct {
+	ResourceGroupName  string
+	StorageAccountName string
+	ContainerName      string
+	Key                string
+}
+
+// remoteBackendFromEnv reads the backend's storage account and container from remoteBackendEnvVars, failing
+// with the unset ones. The Key is left for each deployment to choose.
+func remoteBackendFromEnv() (remoteBackend, error) {
+	unset := []string{}
+	for _, name := range remoteBackendEnvVars {
+		if os.Getenv(name) == "" {
+			unset = append(unset, name)
+		}
+	}
+	if len(unset) > 0 {
+		return remoteBackend{}, fmt.Errorf("remote backend needs %s", strings.Join(unset, ", "))
+	}
+	return remoteBackend{
+		ResourceGroupName:  os.Getenv("BACKEND_RESOURCE_GROUP"),
+		StorageAccountName: os.Getenv("BACKEND_STORAGE_ACCOUNT"),
+		ContainerName:      os.Getenv("BACKEND_CONTAINER"),
+	}, nil
+}
+
+// backendConfig returns the -backend-config values for terraform init
+func (b remoteBackend) backendConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"resource_group_name":  b.ResourceGroupName,
+		"storage_account_name": b.StorageAccountName,
+		"container_name":       b.ContainerName,
+		"key":                  b.Key,
+	}
+}
+
+// writeBackendFileE declares an empty azurerm backend in the module copy at dir
+func writeBackendFileE(dir string) error {
+	return os.WriteFile(filepath.Join(dir, backendFile), []byte("terraform {\n  backend \"azurerm\" {}\n}\n"), 0o644)
+}
+
+// stateBlobE returns a reference to the backend's state blob, authorized with the storage account's key
+func stateBlobE(b remoteBackend, subscriptionID string) (*storage.Blob, error) {
+	op := fmt.Sprintf("get keys of storage account %s in resource group %s", b.StorageAccountName, b.ResourceGroupName)
+	accounts, err := azure.GetStorageAccountClientE(subscriptionID)
+	if err != nil {
+		return nil, wrapAzureErr(op, subscriptionID, err)
+	}
+	keys, err := accounts.ListKeys(context.Background(), b.ResourceGroupName, b.StorageAccountName, "")
+	if err != nil {
+		return nil, wrapAzureErr(op, subscriptionID, err)
+	}
+	if keys.Keys == nil || len(*keys.Keys) == 0 {
+		return nil, fmt.Errorf("storage account %s has no access keys", b.StorageAccountName)
+	}
+
+	client, err := storage.NewBasicClient(b.StorageAccountName, to.String((*keys.Keys)[0].Value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a blob client for storage account %s: %w", b.StorageAccountName, err)
+	}
+	blobs := client.GetBlobService()
+	return blobs.GetContainerReference(b.ContainerName).GetBlobReference(b.Key), nil
+}
+
+// stateBlobExistsE reports whether the backend's state blob exists
+func stateBlobExistsE(b remoteBackend, subscriptionID string) (bool, error) {
+	blob, err := stateBlobE(b, subscriptionID)
+	if err != nil {
+		return false, err
+	}
+	exists, err := blob.Exists()
+	if err != nil {
+		return false, fmt.Errorf("failed to check state blob %s in container %s: %w", b.Key, b.ContainerName, err)
+	}
+	return exists, nil
+}
+
+// deleteStateBlobE deletes the backend's state blob, if it exists
+func deleteStateBlobE(b remoteBackend, subscriptionID string) error {
+	blob, err := stateBlobE(b, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if _, err := blob.DeleteIfExists(nil); err != nil {
+		return fmt.Errorf("failed to delete state blob %s in container %s: %w", b.Key, b.ContainerName, err)
+	}
+	return nil
+}
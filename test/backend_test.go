@@ -0,0 +1,50 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteBackendFromEnv(t *testing.T) {
+	t.Run("Set", func(t *testing.T) {
+		t.Setenv("BACKEND_RESOURCE_GROUP", "tfstate-rg")
+		t.Setenv("BACKEND_STORAGE_ACCOUNT", "tfstate0138")
+		t.Setenv("BACKEND_CONTAINER", "tfstate")
+
+		backend, err := remoteBackendFromEnv()
+		require.NoError(t, err)
+		backend.Key = "lian0138-A05-RG.tfstate"
+
+		// Confirm each value reaches the matching -backend-config setting
+		assert.Equal(t, map[string]interface{}{
+			"resource_group_name":  "tfstate-rg",
+			"storage_account_name": "tfstate0138",
+			"container_name":       "tfstate",
+			"key":                  "lian0138-A05-RG.tfstate",
+		}, backend.backendConfig())
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		t.Setenv("BACKEND_RESOURCE_GROUP", "tfstate-rg")
+		t.Setenv("BACKEND_STORAGE_ACCOUNT", "")
+		t.Setenv("BACKEND_CONTAINER", "")
+
+		// Confirm the error names every missing variable
+		_, err := remoteBackendFromEnv()
+		assert.EqualError(t, err, "remote backend needs BACKEND_STORAGE_ACCOUNT, BACKEND_CONTAINER")
+	})
+}
+
+func TestWriteBackendFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, writeBackendFileE(dir))
+
+	// Confirm the copy declares an empty azurerm backend for -backend-config to fill in
+	declared, err := os.ReadFile(filepath.Join(dir, backendFile))
+	require.NoError(t, err)
+	assert.Equal(t, "terraform {\n  backend \"azurerm\" {}\n}\n", string(declared))
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// childModuleDir is where writeChildModuleE puts the wrapper, relative to the module's root, so its
+// source of ../../ points back at the module
+const childModuleDir = "wrapper/child"
+
+// declaredOutputs lists the names of the module's output blocks, sorted
+func declaredOutputs(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := []string{}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for _, block := range parsed.Body.(*hclsyntax.Body).Blocks {
+			if block.Type == "output" && len(block.Labels) == 1 {
+				outputs = append(outputs, block.Labels[0])
+			}
+		}
+	}
+	sort.Strings(outputs)
+	return outputs, nil
+}
+
+// childModuleConfig renders a root configuration that calls the module as module "web", passes it vars and
+// re-exports each of outputs under the same name. The values are read from vars.json rather than passed
+// with -var, which would turn the wrapper's untyped variables into strings.
+func childModuleConfig(vars map[string]interface{}, outputs []string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var config strings.Builder
+	config.WriteString("locals {\n  vars = jsondecode(file(\"${path.module}/vars.json\"))\n}\n\n")
+	config.WriteString("module \"web\" {\n  source = \"../../\"\n")
+	for _, name := range names {
+		fmt.Fprintf(&config, "\n  %s = local.vars[%q]", name, name)
+	}
+	config.WriteString("\n}\n")
+	for _, name := range outputs {
+		fmt.Fprintf(&config, "\noutput %q {\n  value = module.web.%s\n}\n", name, name)
+	}
+	return config.String()
+}
+
+// writeChildModuleE writes a wrapper that consumes the module at moduleRoot as a child module, re-exporting
+// all its outputs, and returns the wrapper's folder
+func writeChildModuleE(moduleRoot string, vars map[string]interface{}) (string, error) {
+	outputs, err := declaredOutputs(moduleRoot)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the module's outputs: %w", err)
+	}
+	values, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the wrapper's variables: %w", err)
+	}
+
+	dir := filepath.Join(moduleRoot, childModuleDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vars.json"), values, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(childModuleConfig(vars, outputs)), 0o644); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
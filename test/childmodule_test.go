@@ -0,0 +1,64 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclaredOutputs(t *testing.T) {
+	dir := t.TempDir()
+	outputs := "output \"vm_name\" {\n  value = \"vm\"\n}\n\noutput \"location\" {\n  value = \"westus3\"\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outputs.tf"), []byte(outputs), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte("variable \"region\" {}\n"), 0o644))
+
+	declared, err := declaredOutputs(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"location", "vm_name"}, declared)
+}
+
+func TestWriteChildModule(t *testing.T) {
+	root := t.TempDir()
+	outputs := "output \"vm_name\" {\n  value = \"vm\"\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(root, "outputs.tf"), []byte(outputs), 0o644))
+	vars := map[string]interface{}{
+		"labelPrefix": "lian0138",
+		"data_disks":  []interface{}{map[string]interface{}{"size_gb": float64(4), "caching": "ReadWrite"}},
+	}
+
+	dir, err := writeChildModuleE(root, vars)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "wrapper", "child"), dir)
+
+	// Confirm the values round-trip with their types
+	raw, err := os.ReadFile(filepath.Join(dir, "vars.json"))
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, vars, decoded)
+
+	// Confirm the wrapper is valid HCL that calls the module two levels up, passes each variable and
+	// re-exports each output
+	parsed, diags := hclparse.NewParser().ParseHCLFile(filepath.Join(dir, "main.tf"))
+	require.False(t, diags.HasErrors(), diags.Error())
+	blocks := map[string]*hclsyntax.Block{}
+	for _, block := range parsed.Body.(*hclsyntax.Body).Blocks {
+		blocks[strings.Join(append([]string{block.Type}, block.Labels...), ".")] = block
+	}
+	require.Contains(t, blocks, "locals")
+	require.Contains(t, blocks, "module.web")
+	module := blocks["module.web"].Body.Attributes
+	source, diags := module["source"].Expr.Value(nil)
+	require.False(t, diags.HasErrors(), diags.Error())
+	assert.Equal(t, "../../", source.AsString())
+	assert.Contains(t, module, "labelPrefix")
+	assert.Contains(t, module, "data_disks")
+	assert.Contains(t, blocks, "output.vm_name")
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/go-autorest/autorest"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/gruntwork-io/terratest/modules/azure"
+)
+
+// clientConfigE resolves the target subscription (falling back to ARM_SUBSCRIPTION_ID)
+// and an authorizer for SDK clients terratest doesn't wrap
+func clientConfigE(subscriptionID string) (string, autorest.Authorizer, error) {
+	subscriptionID, err := azure.GetTargetAzureSubscription(subscriptionID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	authorizer, err := azure.NewAuthorizer()
+	if err != nil {
+		return "", nil, err
+	}
+	return subscriptionID, *authorizer, nil
+}
+
+// wrapAzureErr names the operation and subscription an SDK call failed for, keeping err for errors.Is/As.
+// An empty subscriptionID is resolved the way the SDK calls resolve it. It returns nil when err is nil.
+func wrapAzureErr(op, subscriptionID string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if resolved, resolveErr := azure.GetTargetAzureSubscription(subscriptionID); resolveErr == nil {
+		subscriptionID = resolved
+	}
+	return fmt.Errorf("%s failed for subscription %s: %w", op, subscriptionID, err)
+}
+
+// getResourceJSONE GETs a resource from Azure Resource Manager at apiVersion and decodes the body into result.
+// It covers properties newer than the pinned SDK's API versions.
+func getResourceJSONE(resourceID, apiVersion string, result interface{}) error {
+	_, authorizer, err := clientConfigE("")
+	if err != nil {
+		return err
+	}
+
+	req, err := autorest.Prepare(&http.Request{},
+		autorest.AsGet(),
+		autorest.WithBaseURL(autorestazure.PublicCloud.ResourceManagerEndpoint),
+		autorest.WithPath(resourceID),
+		autorest.WithQueryParameters(map[string]interface{}{"api-version": apiVersion}),
+		authorizer.WithAuthorization())
+	if err != nil {
+		return err
+	}
+
+	resp, err := autorest.Send(req.WithContext(context.Background()))
+	if err != nil {
+		return err
+	}
+	return autorest.Respond(resp,
+		autorestazure.WithErrorUnlessStatusCode(http.StatusOK),
+		autorest.ByUnmarshallingJSON(result),
+		autorest.ByClosing())
+}
@@ -0,0 +1,21 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapAzureErr(t *testing.T) {
+	cause := errors.New("StatusCode=403 AuthorizationFailed")
+
+	err := wrapAzureErr("get VM lian0138A05VM in resource group lian0138-A05-RG", "sub", cause)
+
+	// Confirm the message names the operation and scope and the SDK error is still reachable
+	require.Error(t, err)
+	assert.Equal(t, "get VM lian0138A05VM in resource group lian0138-A05-RG failed for subscription sub: StatusCode=403 AuthorizationFailed", err.Error())
+	assert.ErrorIs(t, err, cause)
+	assert.NoError(t, wrapAzureErr("get VM", "sub", nil), "A successful call was reported as a failure")
+}
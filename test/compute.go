@@ -0,0 +1,336 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/devtestlabs/mgmt/2018-09-15/dtl"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gruntwork-io/terratest/modules/azure"
+)
+
+// getVirtualMachineNICIDsE fetches the VM until its network profile lists NICs, for up to timeout, and returns
+// their IDs. The profile can be nil for a brief window after creation, so that is retried instead of failed;
+// an SDK error is returned at once.
+func getVirtualMachineNICIDsE(vmName, resourceGroupName, subscriptionID string, timeout, interval time.Duration) ([]string, error) {
+	var ids []string
+	var fetchErr error
+	err := eventuallyE(timeout, interval, func() (bool, string) {
+		vm, err := azureAPI.GetVirtualMachine(vmName, resourceGroupName, subscriptionID)
+		if err != nil {
+			fetchErr = err
+			return true, ""
+		}
+		if vm.VirtualMachineProperties == nil || vm.NetworkProfile == nil || vm.NetworkProfile.NetworkInterfaces == nil {
+			return false, fmt.Sprintf("VM %s has no network interfaces yet", vmName)
+		}
+
+		ids = []string{}
+		for _, nicRef := range *vm.NetworkProfile.NetworkInterfaces {
+			if nicRef.ID != nil {
+				ids = append(ids, *nicRef.ID)
+			}
+		}
+		return len(ids) > 0, fmt.Sprintf("VM %s has no network interface IDs yet", vmName)
+	})
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// checkNICPrimaryFlags checks the VM has expected NICs with exactly one flagged primary.
+// The error reports every NIC's primary flag, since Azure rejects both none and several.
+func checkNICPrimaryFlags(vm *compute.VirtualMachine, expected int) error {
+	if vm.VirtualMachineProperties == nil || vm.NetworkProfile == nil || vm.NetworkProfile.NetworkInterfaces == nil {
+		return fmt.Errorf("VM has no network profile, expected %d NICs", expected)
+	}
+
+	refs := *vm.NetworkProfile.NetworkInterfaces
+	primaries := 0
+	flags := []string{}
+	for _, ref := range refs {
+		primary := ref.NetworkInterfaceReferenceProperties != nil && to.Bool(ref.Primary)
+		if primary {
+			primaries++
+		}
+		flags = append(flags, fmt.Sprintf("%s primary=%t", to.String(ref.ID), primary))
+	}
+
+	if len(refs) != expected || primaries != 1 {
+		return fmt.Errorf("VM has %d NICs with %d primary, expected %d with 1 primary: %s",
+			len(refs), primaries, expected, strings.Join(flags, ", "))
+	}
+	return nil
+}
+
+// getVirtualMachineExtensionsE lists the extensions installed on a VM
+func getVirtualMachineExtensionsE(vmName, resourceGroupName, subscriptionID string) ([]compute.VirtualMachineExtension, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := compute.NewVirtualMachineExtensionsClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	result, err := client.List(context.Background(), resourceGroupName, vmName, "")
+	if err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("list extensions of VM %s in resource group %s", vmName, resourceGroupName), subscriptionID, err)
+	}
+	if result.Value == nil {
+		return nil, nil
+	}
+	return *result.Value, nil
+}
+
+// vmAPIVersion is the compute API version used for VM properties the pinned compute SDK predates
+const vmAPIVersion = "2023-03-01"
+
+// vmSecurityProfile is the VM's trusted launch configuration
+type vmSecurityProfile struct {
+	SecurityType string `json:"securityType"`
+	UefiSettings struct {
+		SecureBootEnabled bool `json:"secureBootEnabled"`
+		VTpmEnabled       bool `json:"vTpmEnabled"`
+	} `json:"uefiSettings"`
+}
+
+// vmProperties holds the VM properties newer than the pinned compute SDK
+type vmProperties struct {
+	// SecurityProfile is zero for VMs without trusted launch
+	SecurityProfile vmSecurityProfile `json:"securityProfile"`
+	StorageProfile  struct {
+		DiskControllerType string `json:"diskControllerType"`
+	} `json:"storageProfile"`
+}
+
+// getVirtualMachinePropertiesE fetches the VM's properties that the pinned compute SDK doesn't model
+func getVirtualMachinePropertiesE(vmName, resourceGroupName, subscriptionID string) (*vmProperties, error) {
+	subscriptionID, err := azure.GetTargetAzureSubscription(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	vmID := ResourceID{subscriptionID, resourceGroupName, "Microsoft.Compute", "virtualMachines", vmName}.String()
+
+	var vm struct {
+		Properties vmProperties `json:"properties"`
+	}
+	if err := getResourceJSONE(vmID, vmAPIVersion, &vm); err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("get properties of VM %s in resource group %s", vmName, resourceGroupName), subscriptionID, err)
+	}
+	return &vm.Properties, nil
+}
+
+// getDiskE gets a managed disk
+func getDiskE(diskName, resourceGroupName, subscriptionID string) (*compute.Disk, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := compute.NewDisksClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	disk, err := client.Get(context.Background(), resourceGroupName, diskName)
+	if err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("get disk %s in resource group %s", diskName, resourceGroupName), subscriptionID, err)
+	}
+	return &disk, nil
+}
+
+// imageAvailableE reports whether a marketplace image has any versions in a region. Azure answers
+// 404 for an offer or SKU the region doesn't carry, so that is reported as unavailable rather than an error.
+func imageAvailableE(location, publisher, offer, sku, subscriptionID string) (bool, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return false, err
+	}
+	client := compute.NewVirtualMachineImagesClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	images, err := client.List(context.Background(), location, publisher, offer, sku, "", nil, "")
+	if err != nil {
+		var detailed autorest.DetailedError
+		if errors.As(err, &detailed) && detailed.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, wrapAzureErr(fmt.Sprintf("list image %s/%s/%s in %s", publisher, offer, sku, location), subscriptionID, err)
+	}
+	return images.Value != nil && len(*images.Value) > 0, nil
+}
+
+// getVMShutdownScheduleE gets a VM's auto-shutdown schedule
+func getVMShutdownScheduleE(scheduleName, resourceGroupName, subscriptionID string) (*dtl.Schedule, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := dtl.NewGlobalSchedulesClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	schedule, err := client.Get(context.Background(), resourceGroupName, scheduleName, "")
+	if err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("get schedule %s in resource group %s", scheduleName, resourceGroupName), subscriptionID, err)
+	}
+	return &schedule, nil
+}
+
+// setVMTagsE changes a VM's tags in place, as an outside tagging system would: keys in changes are set,
+// and keys whose value is nil are removed. Other tags are kept.
+func setVMTagsE(vmName, resourceGroupName, subscriptionID string, changes map[string]*string) error {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return err
+	}
+	client := compute.NewVirtualMachinesClient(subscriptionID)
+	client.Authorizer = authorizer
+	op := fmt.Sprintf("tag VM %s in resource group %s", vmName, resourceGroupName)
+
+	vm, err := client.Get(context.Background(), resourceGroupName, vmName, "")
+	if err != nil {
+		return wrapAzureErr(op, subscriptionID, err)
+	}
+	tags := map[string]*string{}
+	for key, value := range vm.Tags {
+		tags[key] = value
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(tags, key)
+		} else {
+			tags[key] = value
+		}
+	}
+
+	future, err := client.Update(context.Background(), resourceGroupName, vmName, compute.VirtualMachineUpdate{Tags: tags})
+	if err != nil {
+		return wrapAzureErr(op, subscriptionID, err)
+	}
+	return wrapAzureErr(op, subscriptionID, future.WaitForCompletionRef(context.Background(), client.Client))
+}
+
+// listComputeUsagesE lists the subscription's compute usage and quotas in a region, such as regional and
+// per-family vCPUs
+func listComputeUsagesE(location, subscriptionID string) ([]compute.Usage, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := compute.NewUsageClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	usages := []compute.Usage{}
+	iterator, err := client.ListComplete(context.Background(), location)
+	for ; err == nil && iterator.NotDone(); err = iterator.NextWithContext(context.Background()) {
+		usages = append(usages, iterator.Value())
+	}
+	if err != nil {
+		return nil, wrapAzureErr("list compute usage in "+location, subscriptionID, err)
+	}
+	return usages, nil
+}
+
+// getVMSizeFamilyE returns the quota family (e.g. standardBSFamily) and vCPU count of a VM size in a region
+func getVMSizeFamilyE(vmSize, location, subscriptionID string) (string, int, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return "", 0, err
+	}
+	client := compute.NewResourceSkusClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	op := fmt.Sprintf("look up VM size %s in %s", vmSize, location)
+	iterator, err := client.ListComplete(context.Background(), fmt.Sprintf("location eq '%s'", location))
+	for ; err == nil && iterator.NotDone(); err = iterator.NextWithContext(context.Background()) {
+		sku := iterator.Value()
+		if to.String(sku.ResourceType) != "virtualMachines" || !strings.EqualFold(to.String(sku.Name), vmSize) || sku.Capabilities == nil {
+			continue
+		}
+		for _, capability := range *sku.Capabilities {
+			if to.String(capability.Name) == "vCPUs" {
+				vcpus, err := strconv.Atoi(to.String(capability.Value))
+				if err != nil {
+					return "", 0, fmt.Errorf("%s: invalid vCPUs %q: %w", op, to.String(capability.Value), err)
+				}
+				return to.String(sku.Family), vcpus, nil
+			}
+		}
+	}
+	if err != nil {
+		return "", 0, wrapAzureErr(op, subscriptionID, err)
+	}
+	return "", 0, fmt.Errorf("%s: size not offered in the region", op)
+}
+
+// powerState returns the state part of an instance view's PowerState/<state> status, such as running or
+// deallocated, or "" when the view has none yet
+func powerState(statuses *[]compute.InstanceViewStatus) string {
+	if statuses == nil {
+		return ""
+	}
+	for _, status := range *statuses {
+		if state, ok := strings.CutPrefix(to.String(status.Code), "PowerState/"); ok {
+			return state
+		}
+	}
+	return ""
+}
+
+// getVMPowerStateE returns the VM's power state from its instance view
+func getVMPowerStateE(vmName, resourceGroupName, subscriptionID string) (string, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return "", err
+	}
+	client := compute.NewVirtualMachinesClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	view, err := client.InstanceView(context.Background(), resourceGroupName, vmName)
+	if err != nil {
+		return "", wrapAzureErr(fmt.Sprintf("get instance view of VM %s in resource group %s", vmName, resourceGroupName), subscriptionID, err)
+	}
+	return powerState(view.Statuses), nil
+}
+
+// deallocateVME stops the VM and releases its compute, waiting for the operation to finish
+func deallocateVME(vmName, resourceGroupName, subscriptionID string) error {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return err
+	}
+	client := compute.NewVirtualMachinesClient(subscriptionID)
+	client.Authorizer = authorizer
+	op := fmt.Sprintf("deallocate VM %s in resource group %s", vmName, resourceGroupName)
+
+	future, err := client.Deallocate(context.Background(), resourceGroupName, vmName)
+	if err != nil {
+		return wrapAzureErr(op, subscriptionID, err)
+	}
+	return wrapAzureErr(op, subscriptionID, future.WaitForCompletionRef(context.Background(), client.Client))
+}
+
+// startVME starts a stopped or deallocated VM, waiting for the operation to finish
+func startVME(vmName, resourceGroupName, subscriptionID string) error {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return err
+	}
+	client := compute.NewVirtualMachinesClient(subscriptionID)
+	client.Authorizer = authorizer
+	op := fmt.Sprintf("start VM %s in resource group %s", vmName, resourceGroupName)
+
+	future, err := client.Start(context.Background(), resourceGroupName, vmName)
+	if err != nil {
+		return wrapAzureErr(op, subscriptionID, err)
+	}
+	return wrapAzureErr(op, subscriptionID, future.WaitForCompletionRef(context.Background(), client.Client))
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubVirtualMachines replaces the VM fetch with one that returns each response in turn, repeating the last
+func stubVirtualMachines(t *testing.T, responses ...*compute.VirtualMachine) *int {
+	return &useFakeAzure(t, &fakeAzureClient{vms: responses}).vmCalls
+}
+
+// vmWithNICs returns a VM whose network profile references the given NIC IDs; nil leaves the profile empty
+func vmWithNICs(nicIDs []string) *compute.VirtualMachine {
+	if nicIDs == nil {
+		return &compute.VirtualMachine{VirtualMachineProperties: &compute.VirtualMachineProperties{NetworkProfile: &compute.NetworkProfile{}}}
+	}
+	refs := []compute.NetworkInterfaceReference{}
+	for _, id := range nicIDs {
+		refs = append(refs, compute.NetworkInterfaceReference{ID: to.StringPtr(id)})
+	}
+	return &compute.VirtualMachine{VirtualMachineProperties: &compute.VirtualMachineProperties{
+		NetworkProfile: &compute.NetworkProfile{NetworkInterfaces: &refs},
+	}}
+}
+
+func TestNICIDsRetriesNilNetworkProfile(t *testing.T) {
+	calls := stubVirtualMachines(t, vmWithNICs(nil), vmWithNICs(nil), vmWithNICs([]string{"nic-1"}))
+
+	nicIDs, err := getVirtualMachineNICIDsE("vm", "rg", "sub", time.Second, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nic-1"}, nicIDs)
+	assert.Equal(t, 3, *calls, "Expected two retries before the NICs appeared")
+}
+
+func TestNICIDsGivesUpAfterTimeout(t *testing.T) {
+	calls := stubVirtualMachines(t, vmWithNICs(nil))
+
+	_, err := getVirtualMachineNICIDsE("vm", "rg", "sub", 20*time.Millisecond, time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "VM vm has no network interfaces yet")
+	assert.Greater(t, *calls, 1, "Expected retries before giving up")
+}
+
+func TestNICIDsFailsFastOnFetchError(t *testing.T) {
+	fake := useFakeAzure(t, &fakeAzureClient{err: errors.New("authorization failed")})
+
+	_, err := getVirtualMachineNICIDsE("vm", "rg", "sub", time.Second, time.Millisecond)
+	require.Error(t, err)
+	assert.Equal(t, 1, fake.vmCalls, "SDK errors should not be retried")
+}
+
+// vmWithPrimaryFlags returns a VM with one NIC per flag, each marked primary as given
+func vmWithPrimaryFlags(flags ...bool) *compute.VirtualMachine {
+	vm := vmWithNICs([]string{})
+	refs := []compute.NetworkInterfaceReference{}
+	for i, primary := range flags {
+		refs = append(refs, compute.NetworkInterfaceReference{
+			ID:                                  to.StringPtr(fmt.Sprintf("nic-%d", i+1)),
+			NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(primary)},
+		})
+	}
+	vm.NetworkProfile.NetworkInterfaces = &refs
+	return vm
+}
+
+func TestCheckNICPrimaryFlags(t *testing.T) {
+	assert.NoError(t, checkNICPrimaryFlags(vmWithPrimaryFlags(true), 1))
+	assert.NoError(t, checkNICPrimaryFlags(vmWithPrimaryFlags(true, false), 2))
+
+	err := checkNICPrimaryFlags(vmWithPrimaryFlags(true, true), 2)
+	require.Error(t, err, "Two primary NICs were accepted")
+	assert.Contains(t, err.Error(), "nic-1 primary=true, nic-2 primary=true")
+
+	err = checkNICPrimaryFlags(vmWithPrimaryFlags(false), 1)
+	require.Error(t, err, "A NIC without a primary flag was accepted")
+	assert.Contains(t, err.Error(), "1 NICs with 0 primary")
+
+	err = checkNICPrimaryFlags(vmWithPrimaryFlags(true), 2)
+	require.Error(t, err, "A missing NIC was accepted")
+	assert.Contains(t, err.Error(), "expected 2")
+}
+
+func TestPowerState(t *testing.T) {
+	statuses := func(codes ...string) *[]compute.InstanceViewStatus {
+		list := []compute.InstanceViewStatus{}
+		for _, code := range codes {
+			list = append(list, compute.InstanceViewStatus{Code: to.StringPtr(code)})
+		}
+		return &list
+	}
+
+	assert.Equal(t, "running", powerState(statuses("ProvisioningState/succeeded", "PowerState/running")))
+	assert.Equal(t, "deallocated", powerState(statuses("ProvisioningState/updating", "PowerState/deallocated")))
+	assert.Empty(t, powerState(statuses("ProvisioningState/creating")), "A view without a power state gave one")
+	assert.Empty(t, powerState(nil))
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// deployment locates a deployed copy of the module: the subscription and resource group it lives in, and the
+// outputs naming its resources. The existence checks take one instead of reading subscriptionID and
+// sharedOutputs, so they can run against a deployment another process made or one in another subscription.
+type deployment struct {
+	SubscriptionID    string
+	ResourceGroupName string
+	Outputs           Outputs
+}
+
+// newDeployment returns the deployment out describes, in subscriptionID
+func newDeployment(subscriptionID string, out Outputs) deployment {
+	return deployment{SubscriptionID: subscriptionID, ResourceGroupName: out.ResourceGroupName, Outputs: out}
+}
+
+// assertDeployment runs the standard existence checks against the deployment: its VM exists, its NIC exists
+// and is attached, and the VM has the expected NICs. Every check runs so one report shows all that failed.
+func assertDeployment(t assert.TestingT, d deployment) bool {
+	vmOK := assertVMExists(t, d)
+	nicOK := assertNICExistsAndConnected(t, d)
+	countOK := assertNICCount(t, d)
+	return vmOK && nicOK && countOK
+}
+
+// assertVMExists checks the deployment's VM exists
+func assertVMExists(t assert.TestingT, d deployment) bool {
+	exists, err := azureAPI.VirtualMachineExists(d.Outputs.VMName, d.ResourceGroupName, d.SubscriptionID)
+	if !assert.NoError(t, err, "Failed to check the VM") {
+		return false
+	}
+	return assert.True(t, exists, "VM %s does not exist in resource group %s", d.Outputs.VMName, d.ResourceGroupName)
+}
+
+// assertNICExistsAndConnected checks the deployment's NIC exists and is attached to its VM, allowing for a
+// briefly empty network profile after creation
+func assertNICExistsAndConnected(t assert.TestingT, d deployment) bool {
+	exists, err := azureAPI.NetworkInterfaceExists(d.Outputs.NICName, d.ResourceGroupName, d.SubscriptionID)
+	if !assert.NoError(t, err, "Failed to check the NIC") || !assert.True(t, exists, "NIC %s does not exist in resource group %s", d.Outputs.NICName, d.ResourceGroupName) {
+		return false
+	}
+
+	nicIDs, err := getVirtualMachineNICIDsE(d.Outputs.VMName, d.ResourceGroupName, d.SubscriptionID,
+		testTimeouts.NIC, testTimeouts.RetryInterval)
+	if !assert.NoError(t, err, "Failed to get VM network interfaces") {
+		return false
+	}
+	expected := ResourceID{d.SubscriptionID, d.ResourceGroupName, "Microsoft.Network", "networkInterfaces", d.Outputs.NICName}
+	attached := false
+	for _, nicID := range nicIDs {
+		id, err := parseAzureResourceID(nicID)
+		if !assert.NoError(t, err, "VM references a malformed NIC ID") {
+			return false
+		}
+		attached = attached || id.Equal(expected)
+	}
+	return assert.True(t, attached, "NIC %s is not attached to VM, which references %v", expected, nicIDs)
+}
+
+// assertNICCount checks the deployment's VM has the NICs its outputs expect and exactly one is primary
+func assertNICCount(t assert.TestingT, d deployment) bool {
+	vm, err := azureAPI.GetVirtualMachine(d.Outputs.VMName, d.ResourceGroupName, d.SubscriptionID)
+	if !assert.NoError(t, err, "Failed to get VM details") {
+		return false
+	}
+	return assert.NoError(t, checkNICPrimaryFlags(vm, d.Outputs.NICCount), "VM NIC configuration is invalid")
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeploymentChecks(t *testing.T) {
+	// An externally deployed VM in another subscription, whose outputs name a different resource group
+	external := deployment{
+		SubscriptionID:    "11111111-2222-3333-4444-555555555555",
+		ResourceGroupName: "external-rg",
+		Outputs:           Outputs{ResourceGroupName: "lian0138-A05-RG", VMName: "externalVM", NICName: "externalNic", NICCount: 1},
+	}
+	nicID := "/subscriptions/11111111-2222-3333-4444-555555555555/resourceGroups/external-rg/providers/Microsoft.Network/networkInterfaces/externalNic"
+	vm := &compute.VirtualMachine{VirtualMachineProperties: &compute.VirtualMachineProperties{
+		NetworkProfile: &compute.NetworkProfile{NetworkInterfaces: &[]compute.NetworkInterfaceReference{{
+			ID:                                  to.StringPtr(nicID),
+			NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(true)},
+		}}},
+	}}
+
+	t.Run("Passes", func(t *testing.T) {
+		fake := useFakeAzure(t, &fakeAzureClient{existing: []string{"externalVM", "externalNic"}, vms: []*compute.VirtualMachine{vm}})
+		recorder := &failureRecorder{T: t}
+
+		assert.True(t, assertDeployment(recorder, external))
+		assert.Empty(t, recorder.failures)
+
+		// Confirm every lookup used the deployment's subscription and resource group, not the suite's or the outputs'
+		require.NotEmpty(t, fake.scopes)
+		for _, scope := range fake.scopes {
+			assert.Equal(t, "11111111-2222-3333-4444-555555555555/external-rg", scope)
+		}
+	})
+
+	t.Run("NICInOtherResourceGroup", func(t *testing.T) {
+		useFakeAzure(t, &fakeAzureClient{existing: []string{"externalVM", "externalNic"}, vms: []*compute.VirtualMachine{vm}})
+		recorder := &failureRecorder{T: t}
+		elsewhere := external
+		elsewhere.ResourceGroupName = "other-rg"
+
+		// Confirm a NIC of the same name elsewhere doesn't count as attached
+		assert.False(t, assertNICExistsAndConnected(recorder, elsewhere))
+		require.Len(t, recorder.failures, 1)
+		assert.Contains(t, recorder.failures[0], "is not attached to VM")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		useFakeAzure(t, &fakeAzureClient{})
+		recorder := &failureRecorder{T: t}
+
+		// Confirm a missing VM is reported with where it was looked for
+		assert.False(t, assertVMExists(recorder, external))
+		require.Len(t, recorder.failures, 1)
+		assert.Contains(t, recorder.failures[0], "VM externalVM does not exist in resource group external-rg")
+	})
+
+	t.Run("AllChecksReport", func(t *testing.T) {
+		useFakeAzure(t, &fakeAzureClient{})
+		recorder := &failureRecorder{T: t}
+
+		// Confirm a failed check doesn't stop the rest, so the VM and the NIC are both reported missing
+		assert.False(t, assertDeployment(recorder, external))
+		failures := strings.Join(recorder.failures, "\n")
+		assert.Contains(t, failures, "VM externalVM does not exist")
+		assert.Contains(t, failures, "NIC externalNic does not exist")
+	})
+}
+
+func TestNewDeployment(t *testing.T) {
+	d := newDeployment("sub", Outputs{ResourceGroupName: "lian0138-A05-RG", VMName: "lian0138A05VM"})
+
+	// Confirm a deployment from outputs looks in the given subscription and the outputs' resource group
+	assert.Equal(t, "sub", d.SubscriptionID)
+	assert.Equal(t, "lian0138-A05-RG", d.ResourceGroupName)
+	assert.Equal(t, "lian0138A05VM", d.Outputs.VMName)
+}
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envFlag reports whether the named environment variable is set to a true value (1, t, true, ...)
+func envFlag(name string) bool {
+	enabled, err := strconv.ParseBool(os.Getenv(name))
+	return err == nil && enabled
+}
+
+// envString returns the named environment variable, or fallback when it is unset or empty
+func envString(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt returns the named environment variable as an int, or fallback when it is unset or invalid
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// envList splits a comma-separated environment variable into its trimmed, non-empty items
+func envList(name string) []string {
+	items := []string{}
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envDuration parses the named environment variable as a duration (e.g. "30s"), or returns fallback when it is unset or invalid
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
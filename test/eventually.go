@@ -0,0 +1,37 @@
+package test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// eventuallyE calls predicate every interval until it reports true, for up to timeout. The predicate's
+// message says what it saw; if it never reports true, the last message is returned as the error.
+// predicate always runs at least once.
+func eventuallyE(timeout, interval time.Duration, predicate func() (bool, string)) error {
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		ok, message := predicate()
+		if ok {
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("still failing after %d attempts over %s: %s", attempt, timeout, message)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// assertEventually waits for predicate as eventuallyE does and fails the test with the predicate's last
+// message if it never reports true. It returns whether the predicate succeeded.
+func assertEventually(t assert.TestingT, timeout, interval time.Duration, predicate func() (bool, string), msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if err := eventuallyE(timeout, interval, predicate); err != nil {
+		return assert.Fail(t, err.Error(), msgAndArgs...)
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT captures assertion failures instead of failing the real test
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEventuallySucceedsAfterRetries(t *testing.T) {
+	calls := 0
+	predicate := func() (bool, string) {
+		calls++
+		return calls == 3, fmt.Sprintf("attempt %d", calls)
+	}
+	recorder := &recordingT{}
+
+	// Confirm the predicate is retried until it passes and then no longer called
+	assert.True(t, assertEventually(recorder, time.Second, time.Millisecond, predicate))
+	assert.Equal(t, 3, calls, "Expected two retries before the predicate passed")
+	assert.Empty(t, recorder.errors, "A predicate that passed was reported as a failure")
+}
+
+func TestAssertEventuallyReportsLastMessage(t *testing.T) {
+	calls := 0
+	predicate := func() (bool, string) {
+		calls++
+		return false, fmt.Sprintf("NIC list empty on attempt %d", calls)
+	}
+	recorder := &recordingT{}
+
+	ok := assertEventually(recorder, 20*time.Millisecond, time.Millisecond, predicate, "NICs never appeared")
+
+	// Confirm the failure carries the last attempt's message and the caller's context
+	assert.False(t, ok)
+	require.Len(t, recorder.errors, 1)
+	assert.Contains(t, recorder.errors[0], fmt.Sprintf("NIC list empty on attempt %d", calls))
+	assert.Contains(t, recorder.errors[0], "NICs never appeared")
+	assert.Greater(t, calls, 1, "The predicate was not retried")
+}
+
+func TestEventuallyRunsOnceWithoutTime(t *testing.T) {
+	calls := 0
+	err := eventuallyE(0, time.Second, func() (bool, string) {
+		calls++
+		return false, "not ready"
+	})
+
+	require.Error(t, err)
+	assert.Equal(t, 1, calls, "The predicate should run once even with no time to retry")
+}
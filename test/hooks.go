@@ -0,0 +1,97 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// hook runs around a deployment, e.g. to seed a resource group or SSH keys before apply or to
+// clean up something the module doesn't own after destroy
+type hook func(t testing.TestingT, options *terraform.Options) error
+
+// Hooks registered by tests or by init functions in lab-specific files
+var (
+	preApplyHooks    []hook
+	postDestroyHooks []hook
+)
+
+// terraformInitAndApplyE runs `terraform init` and `terraform apply`; unit tests swap it for a stub
+var terraformInitAndApplyE = terraform.InitAndApplyE
+
+// runHookScriptE runs a hook script; unit tests swap it for a fake runner
+var runHookScriptE = shell.RunCommandE
+
+// registerPreApplyHook adds a hook to run before the fixture is applied
+func registerPreApplyHook(h hook) {
+	preApplyHooks = append(preApplyHooks, h)
+}
+
+// registerPostDestroyHook adds a hook to run after the fixture is destroyed
+func registerPostDestroyHook(h hook) {
+	postDestroyHooks = append(postDestroyHooks, h)
+}
+
+// scriptHook runs the script at path, passing the module directory and labelPrefix in the environment
+func scriptHook(path string) hook {
+	return func(t testing.TestingT, options *terraform.Options) error {
+		return runHookScriptE(t, shell.Command{
+			Command: path,
+			Env: map[string]string{
+				"TF_MODULE_DIR": options.TerraformDir,
+				"LABEL_PREFIX":  fmt.Sprint(options.Vars["labelPrefix"]),
+			},
+		})
+	}
+}
+
+// withScript appends a scriptHook for the script named by the environment variable, if it is set
+func withScript(hooks []hook, envVar string) []hook {
+	if path := os.Getenv(envVar); path != "" {
+		return append(append([]hook{}, hooks...), scriptHook(path))
+	}
+	return hooks
+}
+
+// runHooksE runs hooks in order, stopping at the first failure
+func runHooksE(t testing.TestingT, stage string, hooks []hook, options *terraform.Options) error {
+	for i, h := range hooks {
+		if err := h(t, options); err != nil {
+			return fmt.Errorf("%s hook %d failed: %w", stage, i+1, err)
+		}
+	}
+	return nil
+}
+
+// runPreApplyHooksE runs the registered pre-apply hooks, then PRE_APPLY_SCRIPT if it is set
+func runPreApplyHooksE(t testing.TestingT, options *terraform.Options) error {
+	return runHooksE(t, "pre-apply", withScript(preApplyHooks, "PRE_APPLY_SCRIPT"), options)
+}
+
+// runPostDestroyHooksE runs the registered post-destroy hooks, then POST_DESTROY_SCRIPT if it is set
+func runPostDestroyHooksE(t testing.TestingT, options *terraform.Options) error {
+	return runHooksE(t, "post-destroy", withScript(postDestroyHooks, "POST_DESTROY_SCRIPT"), options)
+}
+
+// applyWithHooksE runs the pre-apply hooks, then `terraform init` and `terraform apply`, saving the apply's
+// output when SAVE_APPLY_OUTPUT is set and the resulting state when SAVE_STATE_JSON is set
+func applyWithHooksE(t testing.TestingT, options *terraform.Options) error {
+	if err := runPreApplyHooksE(t, options); err != nil {
+		return err
+	}
+	output, err := terraformInitAndApplyE(t, options)
+	if _, saveErr := saveApplyOutputE(t.Name(), options.TerraformDir, output, err); saveErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to save apply output: %w", saveErr))
+	}
+	if err != nil {
+		return err
+	}
+	if _, saveErr := saveStateJSONE(t, options); saveErr != nil {
+		return fmt.Errorf("failed to save state JSON: %w", saveErr)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubHooks clears the registered hooks and hook scripts for the duration of the test
+func stubHooks(t *testing.T) {
+	originalPre, originalPost := preApplyHooks, postDestroyHooks
+	preApplyHooks, postDestroyHooks = nil, nil
+	t.Setenv("PRE_APPLY_SCRIPT", "")
+	t.Setenv("POST_DESTROY_SCRIPT", "")
+	t.Cleanup(func() { preApplyHooks, postDestroyHooks = originalPre, originalPost })
+}
+
+// stubApply replaces the init and apply runner for the duration of the test, appending "apply" to events on each call
+func stubApply(t *testing.T, events *[]string) {
+	original := terraformInitAndApplyE
+	terraformInitAndApplyE = func(terratesting.TestingT, *terraform.Options) (string, error) {
+		*events = append(*events, "apply")
+		return "", nil
+	}
+	t.Cleanup(func() { terraformInitAndApplyE = original })
+}
+
+func TestPreApplyHookRunsOnceBeforeApply(t *testing.T) {
+	stubHooks(t)
+	events := []string{}
+	stubApply(t, &events)
+	registerPreApplyHook(func(terratesting.TestingT, *terraform.Options) error {
+		events = append(events, "hook")
+		return nil
+	})
+
+	require.NoError(t, applyWithHooksE(t, &terraform.Options{TerraformDir: "../"}))
+
+	// Confirm the hook ran exactly once and before apply
+	assert.Equal(t, []string{"hook", "apply"}, events)
+}
+
+func TestFailingPreApplyHookSkipsApply(t *testing.T) {
+	stubHooks(t)
+	events := []string{}
+	stubApply(t, &events)
+	registerPreApplyHook(func(terratesting.TestingT, *terraform.Options) error {
+		return errors.New("resource group already exists")
+	})
+
+	err := applyWithHooksE(t, &terraform.Options{TerraformDir: "../"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pre-apply hook 1 failed")
+	assert.Empty(t, events, "Apply ran after a pre-apply hook failed")
+}
+
+func TestHookScriptsFromEnv(t *testing.T) {
+	stubHooks(t)
+	commands := []shell.Command{}
+	original := runHookScriptE
+	runHookScriptE = func(_ terratesting.TestingT, command shell.Command) error {
+		commands = append(commands, command)
+		return nil
+	}
+	t.Cleanup(func() { runHookScriptE = original })
+	t.Setenv("POST_DESTROY_SCRIPT", "./scripts/cleanup.sh")
+	options := &terraform.Options{TerraformDir: "/tmp/module", Vars: map[string]interface{}{"labelPrefix": "lian0138"}}
+
+	require.NoError(t, runPreApplyHooksE(t, options))
+	assert.Empty(t, commands, "A pre-apply script ran without PRE_APPLY_SCRIPT")
+
+	// Confirm the post-destroy script ran with the deployment's details in its environment
+	require.NoError(t, runPostDestroyHooksE(t, options))
+	require.Len(t, commands, 1)
+	assert.Equal(t, "./scripts/cleanup.sh", commands[0].Command)
+	assert.Equal(t, "/tmp/module", commands[0].Env["TF_MODULE_DIR"])
+	assert.Equal(t, "lian0138", commands[0].Env["LABEL_PREFIX"])
+}
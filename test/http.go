@@ -0,0 +1,158 @@
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	http_helper "github.com/gruntwork-io/terratest/modules/http-helper"
+)
+
+// webURL returns the web server's URL for path, which should start with "/"
+func webURL(out Outputs, path string) string {
+	return fmt.Sprintf("http://%s%s", out.PublicIP, path)
+}
+
+// bodyContains returns an HTTP validator accepting a 200 response whose body contains marker
+func bodyContains(marker string) func(int, string) bool {
+	return func(status int, body string) bool {
+		return status == 200 && strings.Contains(body, marker)
+	}
+}
+
+// prometheusSample matches a sample line in the Prometheus text format: a metric name, optional labels and a number
+var prometheusSample = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{.*\})? [-+]?([0-9.eE+-]+|NaN|[+-]?Inf)( -?[0-9]+)?$`)
+
+// prometheusText returns an HTTP validator accepting a 200 response in the Prometheus text exposition
+// format: at least one # HELP or # TYPE line and one numeric sample
+func prometheusText(status int, body string) bool {
+	described, sampled := false, false
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE "):
+			described = true
+		case prometheusSample.MatchString(line):
+			sampled = true
+		}
+	}
+	return status == 200 && described && sampled
+}
+
+// waitForHTTP GETs url until validate accepts the response, for up to HTTP_TIMEOUT while cloud-init
+// finishes installing the web server, and returns the last body
+func waitForHTTP(t *testing.T, url string, validate func(int, string) bool) string {
+	var body string
+	ok := assertEventually(t, testTimeouts.HTTP, testTimeouts.RetryInterval, func() (bool, string) {
+		status, responseBody, err := http_helper.HttpGetE(t, url, nil)
+		if err != nil {
+			return false, err.Error()
+		}
+		body = responseBody
+		return validate(status, responseBody), fmt.Sprintf("status %d, body %.200q", status, responseBody)
+	}, "%s never returned the expected response", url)
+	if !ok {
+		t.FailNow()
+	}
+	return body
+}
+
+// redirectClient returns redirects as responses instead of following them
+var redirectClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// waitForRedirect GETs url without following redirects until it answers with a redirect, for up to
+// HTTP_TIMEOUT, and returns the status and Location header
+func waitForRedirect(t *testing.T, url string) (int, string) {
+	var status int
+	var location string
+	ok := assertEventually(t, testTimeouts.HTTP, testTimeouts.RetryInterval, func() (bool, string) {
+		resp, err := redirectClient.Get(url)
+		if err != nil {
+			return false, err.Error()
+		}
+		resp.Body.Close()
+		status, location = resp.StatusCode, resp.Header.Get("Location")
+		return status >= 300 && status < 400, fmt.Sprintf("%s answered %d, not a redirect", url, status)
+	}, "%s never redirected", url)
+	if !ok {
+		t.FailNow()
+	}
+	return status, location
+}
+
+// plainClient leaves response bodies exactly as sent, so Content-Encoding can be checked and the body
+// decoded by the caller
+var plainClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: &http.Transport{DisableCompression: true},
+}
+
+// getEncodedE GETs url with Accept-Encoding set to encoding and returns the response headers and the
+// body as sent, without decompressing it
+func getEncodedE(url string, encoding string) (http.Header, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept-Encoding", encoding)
+	resp, err := plainClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.Header, nil, fmt.Errorf("%s answered %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	return resp.Header, body, err
+}
+
+// rawResponse is a response read off a raw connection, with its wire form for failure reports
+type rawResponse struct {
+	*http.Response
+	Raw string
+}
+
+// rawHTTPE writes each request verbatim to one TCP connection to addr (host:port), reading a full response
+// after each, so tests control the protocol version and headers exactly. It stops at the first failure,
+// returning the responses read so far.
+func rawHTTPE(addr string, timeout time.Duration, requests ...string) ([]rawResponse, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	responses := []rawResponse{}
+	for i, request := range requests {
+		if _, err := conn.Write([]byte(request)); err != nil {
+			return responses, fmt.Errorf("failed to write request %d: %w", i+1, err)
+		}
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			return responses, fmt.Errorf("failed to read response %d: %w", i+1, err)
+		}
+		raw, err := httputil.DumpResponse(resp, true)
+		resp.Body.Close()
+		if err != nil {
+			return responses, fmt.Errorf("failed to read response %d: %w", i+1, err)
+		}
+		responses = append(responses, rawResponse{resp, string(raw)})
+	}
+	return responses, nil
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyContains(t *testing.T) {
+	validate := bodyContains("Hello from lian0138")
+
+	assert.True(t, validate(200, "<h1>Hello from lian0138</h1>"))
+	assert.False(t, validate(200, "Apache2 Ubuntu Default Page"), "The default page was accepted")
+	assert.False(t, validate(503, "Hello from lian0138"), "An error status was accepted")
+}
+
+func TestPrometheusText(t *testing.T) {
+	metrics := "# HELP node_load1 1m load average.\n# TYPE node_load1 gauge\nnode_load1 0.08\nnode_cpu_seconds_total{cpu=\"0\",mode=\"idle\"} 1.2e+03\n"
+
+	assert.True(t, prometheusText(200, metrics))
+	assert.False(t, prometheusText(200, "<h1>Hello from lian0138</h1>"), "An HTML page was accepted")
+	assert.False(t, prometheusText(200, "# HELP node_load1 1m load average.\n"), "Metrics without a sample were accepted")
+	assert.False(t, prometheusText(404, metrics), "An error status was accepted")
+}
+
+func TestRawHTTPKeepAlive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Proto, r.URL.Path)
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	request := "GET /%s HTTP/1.1\r\nHost: test\r\nConnection: keep-alive\r\n\r\n"
+
+	// Confirm both requests are answered in turn over the one connection
+	responses, err := rawHTTPE(addr, 5*time.Second, fmt.Sprintf(request, "first"), fmt.Sprintf(request, "second"))
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	assert.False(t, responses[0].Close, "Server closed a keep-alive connection")
+	assert.Contains(t, responses[0].Raw, "HTTP/1.1 /first")
+	assert.Contains(t, responses[1].Raw, "HTTP/1.1 /second")
+}
+
+func TestGetEncoded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			fmt.Fprint(w, "hello")
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		writer := gzip.NewWriter(w)
+		fmt.Fprint(writer, "hello")
+		writer.Close()
+	}))
+	defer server.Close()
+
+	// Confirm the body comes back still compressed, with its Content-Encoding
+	header, body, err := getEncodedE(server.URL, "gzip")
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", header.Get("Content-Encoding"))
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err, "Body is not gzip")
+	decoded, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(decoded))
+
+	header, body, err = getEncodedE(server.URL, "identity")
+	require.NoError(t, err)
+	assert.Empty(t, header.Get("Content-Encoding"))
+	assert.Equal(t, "hello", string(body))
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ubuntuSupport is when an Ubuntu LTS release leaves Canonical's standard support, after which it only gets
+// security fixes through Ubuntu Pro's Expanded Security Maintenance (ESM)
+type ubuntuSupport struct {
+	StandardSupportEnds time.Time
+	ESMEnds             time.Time
+}
+
+// ubuntuReleases lists the support timelines of the Ubuntu releases the module's images cover, from
+// https://ubuntu.com/about/release-cycle
+var ubuntuReleases = map[string]ubuntuSupport{
+	"18.04": {time.Date(2023, time.May, 31, 0, 0, 0, 0, time.UTC), time.Date(2028, time.April, 30, 0, 0, 0, 0, time.UTC)},
+	"20.04": {time.Date(2025, time.May, 31, 0, 0, 0, 0, time.UTC), time.Date(2030, time.April, 30, 0, 0, 0, 0, time.UTC)},
+	"22.04": {time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC), time.Date(2032, time.April, 30, 0, 0, 0, 0, time.UTC)},
+	"24.04": {time.Date(2029, time.April, 30, 0, 0, 0, 0, time.UTC), time.Date(2034, time.April, 30, 0, 0, 0, 0, time.UTC)},
+}
+
+// imageEOLWarning is how long before the end of standard support an image is reported as nearing it
+const imageEOLWarning = 180 * 24 * time.Hour
+
+// ubuntuVersion matches the release in a Canonical offer or SKU, such as 22_04-lts-gen2, ubuntu-24_04-lts
+// or 18.04-LTS
+var ubuntuVersion = regexp.MustCompile(`(\d{2})[._](04|10)`)
+
+// ubuntuRelease returns the Ubuntu release (e.g. 22.04) an image is for, read from its SKU or else its
+// offer, or "" when neither names one
+func ubuntuRelease(offer, sku string) string {
+	for _, name := range []string{sku, offer} {
+		if match := ubuntuVersion.FindStringSubmatch(name); match != nil {
+			return match[1] + "." + match[2]
+		}
+	}
+	return ""
+}
+
+// checkImageEOLE describes where the image stands against its release's support timeline at now. It
+// returns an error only when standard support has ended; nearing the end, within imageEOLWarning, is
+// reported in the description. Releases without an entry in ubuntuReleases are described as unknown.
+func checkImageEOLE(offer, sku string, now time.Time) (string, error) {
+	image := fmt.Sprintf("%s/%s", offer, sku)
+	release := ubuntuRelease(offer, sku)
+	support, known := ubuntuReleases[release]
+	if !known {
+		return fmt.Sprintf("%s has no known support timeline", image), nil
+	}
+
+	ends, esmEnds := support.StandardSupportEnds.Format(time.DateOnly), support.ESMEnds.Format(time.DateOnly)
+	switch {
+	case !now.Before(support.StandardSupportEnds):
+		return "", fmt.Errorf("%s is Ubuntu %s, whose standard support ended on %s (ESM only until %s)", image, release, ends, esmEnds)
+	case support.StandardSupportEnds.Sub(now) < imageEOLWarning:
+		days := int(support.StandardSupportEnds.Sub(now).Hours() / 24)
+		return fmt.Sprintf("%s is Ubuntu %s, whose standard support ends in %d days on %s (ESM until %s); plan a move to a newer release", image, release, days, ends, esmEnds), nil
+	default:
+		return fmt.Sprintf("%s is Ubuntu %s, supported until %s", image, release, ends), nil
+	}
+}
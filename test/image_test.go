@@ -0,0 +1,54 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestImageNotEOL checks the configured image's Ubuntu release is still in standard support. A release
+// past it fails the test unless IMAGE_EOL_MODE is warn, when it is only logged.
+func TestImageNotEOL(t *testing.T) {
+	offer, sku := optionsImage(&terraform.Options{Vars: moduleVars(t, nil, nil)})
+
+	status, err := checkImageEOLE(offer, sku, time.Now())
+	if err == nil {
+		t.Log(status)
+		return
+	}
+	if envString("IMAGE_EOL_MODE", "fail") == "warn" {
+		t.Logf("WARNING: %v", err)
+		return
+	}
+	t.Errorf("%v; update image_offer and image_sku, or set IMAGE_EOL_MODE=warn", err)
+}
+
+func TestUbuntuRelease(t *testing.T) {
+	assert.Equal(t, "22.04", ubuntuRelease("0001-com-ubuntu-server-jammy", "22_04-lts-gen2"))
+	assert.Equal(t, "20.04", ubuntuRelease("0001-com-ubuntu-server-focal", "20_04-lts"))
+	assert.Equal(t, "24.04", ubuntuRelease("ubuntu-24_04-lts", "server"))
+	assert.Equal(t, "18.04", ubuntuRelease("UbuntuServer", "18.04-LTS"))
+	assert.Empty(t, ubuntuRelease("RHEL", "9-lvm-gen2"))
+}
+
+func TestCheckImageEOL(t *testing.T) {
+	ends := ubuntuReleases["22.04"].StandardSupportEnds
+
+	status, err := checkImageEOLE(defaultImageOffer, defaultImageSKU, ends.AddDate(-1, 0, 0))
+	require.NoError(t, err)
+	assert.Contains(t, status, "Ubuntu 22.04, supported until 2027-04-30")
+
+	status, err = checkImageEOLE(defaultImageOffer, defaultImageSKU, ends.AddDate(0, 0, -30))
+	require.NoError(t, err, "An image nearing its end of support failed")
+	assert.Contains(t, status, "ends in 30 days on 2027-04-30")
+
+	_, err = checkImageEOLE(defaultImageOffer, defaultImageSKU, ends)
+	assert.ErrorContains(t, err, "Ubuntu 22.04, whose standard support ended on 2027-04-30 (ESM only until 2032-04-30)")
+
+	status, err = checkImageEOLE("RHEL", "9-lvm-gen2", ends)
+	require.NoError(t, err, "An image with no known timeline failed")
+	assert.Contains(t, status, "RHEL/9-lvm-gen2 has no known support timeline")
+}
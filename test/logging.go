@@ -0,0 +1,161 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// logTimestampLayout is the timestamp format embedded in log file names (YYYYMMDD_HHMMSS)
+const logTimestampLayout = "20060102_150405"
+
+// setupLogging creates a timestamped log file in the working directory.
+// The returned cleanup func flushes and closes the file; it is safe to call more than once.
+func setupLogging() (*os.File, func(), error) {
+	logFileName := fmt.Sprintf("test_%s.log", time.Now().Format(logTimestampLayout))
+	logFile, err := os.Create(logFileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var closeOnce sync.Once
+	cleanup := func() {
+		closeOnce.Do(func() {
+			logFile.Sync()
+			logFile.Close()
+		})
+	}
+
+	return logFile, cleanup, nil
+}
+
+// saveApplyOutputE appends the output of an apply to apply_<timestamp>.log in the working directory when
+// SAVE_APPLY_OUTPUT is set, whether or not the apply succeeded, and returns the file's name ("" when unset).
+// It is kept apart from the test log so runs can be archived or graded from the apply output alone; applies
+// that finish in the same second share a file, each under a header naming its test and module directory.
+func saveApplyOutputE(testName, moduleDir, output string, applyErr error) (string, error) {
+	if !envFlag("SAVE_APPLY_OUTPUT") {
+		return "", nil
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("apply_%s.log", now.Format(logTimestampLayout))
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	result := "succeeded"
+	if applyErr != nil {
+		result = fmt.Sprintf("failed: %v", applyErr)
+	}
+	_, err = fmt.Fprintf(file, "=== %s: apply in %s at %s %s\n%s\n", testName, moduleDir, now.Format(time.RFC3339), result, output)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// saveStateJSONE writes `terraform show -json` for the deployment in options to state_<timestamp>_<test>.json
+// in the working directory when SAVE_STATE_JSON is set, and returns the file's name ("" when unset). Unlike
+// the outputs it records every attribute of every resource, for grading and audits after the run, so secrets
+// are redacted first as redactStateJSON describes.
+func saveStateJSONE(t testing.TestingT, options *terraform.Options) (string, error) {
+	if !envFlag("SAVE_STATE_JSON") {
+		return "", nil
+	}
+
+	output, err := terraformCommandE(t, options, "show", "-json")
+	if err != nil {
+		return "", err
+	}
+	redactedState, err := redactStateJSON([]byte(output))
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("state_%s_%s.json", time.Now().Format(logTimestampLayout), strings.ReplaceAll(t.Name(), "/", "_"))
+	if err := os.WriteFile(name, redactedState, 0o600); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// redactedValue replaces secrets in saved state
+const redactedValue = "REDACTED"
+
+// secretAttribute matches attribute names that hold secrets even where the provider doesn't mark them sensitive
+var secretAttribute = regexp.MustCompile(`(?i)(private_key|password|secret|access_key|connection_string)`)
+
+// redactStateJSON parses `terraform show -json` output and redacts the values Terraform marks sensitive
+// (in each resource's sensitive_values and on outputs), as well as any string attribute whose name
+// matches secretAttribute, returning the result indented
+func redactStateJSON(raw []byte) ([]byte, error) {
+	var state interface{}
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, fmt.Errorf("terraform show -json output is not valid JSON: %w", err)
+	}
+	return json.MarshalIndent(redactState(state), "", "  ")
+}
+
+// redactState redacts secrets in a decoded state value, in place where it can
+func redactState(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if marks, ok := v["sensitive_values"]; ok {
+			v["values"] = redactMarked(v["values"], marks)
+		}
+		if sensitive, _ := v["sensitive"].(bool); sensitive {
+			if _, ok := v["value"]; ok {
+				v["value"] = redactedValue
+			}
+		}
+		for key, child := range v {
+			if text, ok := child.(string); ok && text != "" && secretAttribute.MatchString(key) {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactState(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactState(child)
+		}
+	}
+	return value
+}
+
+// redactMarked redacts the parts of value that marks, a sensitive_values tree of the same shape, sets to true
+func redactMarked(value, marks interface{}) interface{} {
+	switch m := marks.(type) {
+	case bool:
+		if m && value != nil {
+			return redactedValue
+		}
+	case map[string]interface{}:
+		if v, ok := value.(map[string]interface{}); ok {
+			for key, mark := range m {
+				if child, ok := v[key]; ok {
+					v[key] = redactMarked(child, mark)
+				}
+			}
+		}
+	case []interface{}:
+		if v, ok := value.([]interface{}); ok {
+			for i := range m {
+				if i < len(v) {
+					v[i] = redactMarked(v[i], m[i])
+				}
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/marketplaceordering/mgmt/2015-06-01/marketplaceordering"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// marketplacePlan identifies a marketplace image plan
+type marketplacePlan struct {
+	Publisher string
+	Product   string
+	Name      string
+}
+
+// parseMarketplacePlan parses a plan written as publisher:product:name, the same order `az vm image terms` uses
+func parseMarketplacePlan(value string) (marketplacePlan, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return marketplacePlan{}, fmt.Errorf("invalid marketplace plan %q, expected publisher:product:name", value)
+	}
+	return marketplacePlan{Publisher: parts[0], Product: parts[1], Name: parts[2]}, nil
+}
+
+// marketplaceAgreementsClientE returns an authorized marketplace agreements client
+func marketplaceAgreementsClientE(subscriptionID string) (*marketplaceordering.MarketplaceAgreementsClient, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := marketplaceordering.NewMarketplaceAgreementsClient(subscriptionID)
+	client.Authorizer = authorizer
+	return &client, nil
+}
+
+// ensureMarketplaceTermsE checks the plan's terms are accepted in the subscription, which Azure requires
+// before it deploys the image. With accept set, unaccepted terms are signed instead of reported.
+func ensureMarketplaceTermsE(plan marketplacePlan, subscriptionID string, accept bool) error {
+	client, err := marketplaceAgreementsClientE(subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	terms, err := client.Get(context.Background(), plan.Publisher, plan.Product, plan.Name)
+	if err != nil {
+		return wrapAzureErr(fmt.Sprintf("get marketplace terms for %s:%s:%s", plan.Publisher, plan.Product, plan.Name), subscriptionID, err)
+	}
+	if terms.AgreementProperties != nil && to.Bool(terms.Accepted) {
+		return nil
+	}
+
+	if !accept {
+		return fmt.Errorf("marketplace terms for %s:%s:%s are not accepted, so apply would fail; accept them with "+
+			"`az vm image terms accept --publisher %s --offer %s --plan %s` or set ACCEPT_MARKETPLACE_TERMS=true",
+			plan.Publisher, plan.Product, plan.Name, plan.Publisher, plan.Product, plan.Name)
+	}
+	if _, err := client.Sign(context.Background(), plan.Publisher, plan.Product, plan.Name); err != nil {
+		return wrapAzureErr(fmt.Sprintf("accept marketplace terms for %s:%s:%s", plan.Publisher, plan.Product, plan.Name), subscriptionID, err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMarketplacePlan(t *testing.T) {
+	plan, err := parseMarketplacePlan("bitnami:nginxstack:1-9")
+	require.NoError(t, err)
+	assert.Equal(t, marketplacePlan{Publisher: "bitnami", Product: "nginxstack", Name: "1-9"}, plan)
+
+	for _, invalid := range []string{"", "bitnami:nginxstack", "bitnami::1-9", "a:b:c:d"} {
+		_, err := parseMarketplacePlan(invalid)
+		assert.Error(t, err, "Accepted invalid plan %q", invalid)
+	}
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-09-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/privatedns/mgmt/2018-09-01/privatedns"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gruntwork-io/terratest/modules/azure"
+)
+
+// getBastionHostE gets a bastion host
+func getBastionHostE(bastionName, resourceGroupName, subscriptionID string) (*network.BastionHost, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := network.NewBastionHostsClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	bastion, err := client.Get(context.Background(), resourceGroupName, bastionName)
+	if err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("get bastion host %s in resource group %s", bastionName, resourceGroupName), subscriptionID, err)
+	}
+	return &bastion, nil
+}
+
+// getPrivateDNSVirtualNetworkLinkE gets a private DNS zone's virtual network link
+func getPrivateDNSVirtualNetworkLinkE(linkName, zoneName, resourceGroupName, subscriptionID string) (*privatedns.VirtualNetworkLink, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := privatedns.NewVirtualNetworkLinksClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	link, err := client.Get(context.Background(), resourceGroupName, zoneName, linkName)
+	if err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("get DNS link %s of zone %s in resource group %s", linkName, zoneName, resourceGroupName), subscriptionID, err)
+	}
+	return &link, nil
+}
+
+// nsgExistsE reports whether a network security group exists
+func nsgExistsE(nsgName, resourceGroupName, subscriptionID string) (bool, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return false, err
+	}
+	client := network.NewSecurityGroupsClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	if _, err := client.Get(context.Background(), resourceGroupName, nsgName, ""); err != nil {
+		if azure.ResourceNotFoundErrorExists(err) {
+			return false, nil
+		}
+		return false, wrapAzureErr(fmt.Sprintf("get NSG %s in resource group %s", nsgName, resourceGroupName), subscriptionID, err)
+	}
+	return true, nil
+}
+
+// listPublicIPNamesE lists the names of the public IPs in a resource group
+func listPublicIPNamesE(resourceGroupName, subscriptionID string) ([]string, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := network.NewPublicIPAddressesClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	names := []string{}
+	page, err := client.ListComplete(context.Background(), resourceGroupName)
+	for ; err == nil && page.NotDone(); err = page.NextWithContext(context.Background()) {
+		if name := page.Value().Name; name != nil {
+			names = append(names, *name)
+		}
+	}
+	if err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("list public IPs in resource group %s", resourceGroupName), subscriptionID, err)
+	}
+	return names, nil
+}
+
+// enabledLogCategoriesE lists the log categories a resource's diagnostic setting has enabled
+func enabledLogCategoriesE(settingName, resourceID, subscriptionID string) ([]string, error) {
+	setting, err := azure.GetDiagnosticsSettingsResourceE(settingName, resourceID, subscriptionID)
+	if err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("get diagnostic setting %s of %s", settingName, resourceID), subscriptionID, err)
+	}
+	if setting.DiagnosticSettings == nil || setting.Logs == nil {
+		return []string{}, nil
+	}
+
+	categories := []string{}
+	for _, log := range *setting.Logs {
+		if to.Bool(log.Enabled) && log.Category != nil {
+			categories = append(categories, *log.Category)
+		}
+	}
+	return categories, nil
+}
+
+// getDNSARecordE gets an A record set from a public DNS zone
+func getDNSARecordE(recordName, zoneName, resourceGroupName, subscriptionID string) (*dns.RecordSet, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := dns.NewRecordSetsClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	record, err := client.Get(context.Background(), resourceGroupName, zoneName, recordName, dns.A)
+	if err != nil {
+		return nil, wrapAzureErr(fmt.Sprintf("get A record %s in zone %s", recordName, zoneName), subscriptionID, err)
+	}
+	return &record, nil
+}
+
+// nsgDefaultRules are the rules Azure adds to every NSG, at priority 65000 and up
+var nsgDefaultRules = map[string]bool{
+	"AllowVnetInBound":              true,
+	"AllowAzureLoadBalancerInBound": true,
+	"DenyAllInBound":                true,
+	"AllowVnetOutBound":             true,
+	"AllowInternetOutBound":         true,
+	"DenyAllOutBound":               true,
+}
+
+// nsgDefaultPriority is the priority of Azure's first default rule; custom rules must come before it
+const nsgDefaultPriority = 65000
+
+// nsgPriorityConflicts returns a description of each custom rule that isn't ahead of Azure's default rules,
+// and of each priority two custom rules share in the same direction, sorted. Azure only requires priorities
+// to be unique within a direction, so an inbound and an outbound rule may share one.
+func nsgPriorityConflicts(rules []azure.NsgRuleSummary) []string {
+	conflicts := []string{}
+	byPriority := map[string][]string{}
+	for _, rule := range rules {
+		if nsgDefaultRules[rule.Name] {
+			continue
+		}
+		if rule.Priority >= nsgDefaultPriority {
+			conflicts = append(conflicts, fmt.Sprintf("%s rule %s has priority %d, not ahead of the default rules at %d", rule.Direction, rule.Name, rule.Priority, nsgDefaultPriority))
+		}
+		key := fmt.Sprintf("%s %d", rule.Direction, rule.Priority)
+		byPriority[key] = append(byPriority[key], rule.Name)
+	}
+	for key, names := range byPriority {
+		if len(names) > 1 {
+			sort.Strings(names)
+			conflicts = append(conflicts, fmt.Sprintf("%s priority is shared by %v", key, names))
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// getAppGatewayBackendHealthE gets an application gateway's backend health, waiting while Azure probes the backends
+func getAppGatewayBackendHealthE(gatewayName, resourceGroupName, subscriptionID string) (*network.ApplicationGatewayBackendHealth, error) {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := network.NewApplicationGatewaysClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	op := fmt.Sprintf("get backend health of application gateway %s in resource group %s", gatewayName, resourceGroupName)
+	future, err := client.BackendHealth(context.Background(), resourceGroupName, gatewayName, "")
+	if err != nil {
+		return nil, wrapAzureErr(op, subscriptionID, err)
+	}
+	if err := future.WaitForCompletionRef(context.Background(), client.Client); err != nil {
+		return nil, wrapAzureErr(op, subscriptionID, err)
+	}
+	health, err := future.Result(client)
+	if err != nil {
+		return nil, wrapAzureErr(op, subscriptionID, err)
+	}
+	return &health, nil
+}
+
+// backendServerHealth maps each backend server's address to the health the gateway's probe reports for it
+func backendServerHealth(health network.ApplicationGatewayBackendHealth) map[string]network.ApplicationGatewayBackendHealthServerHealth {
+	servers := map[string]network.ApplicationGatewayBackendHealthServerHealth{}
+	if health.BackendAddressPools == nil {
+		return servers
+	}
+	for _, pool := range *health.BackendAddressPools {
+		if pool.BackendHTTPSettingsCollection == nil {
+			continue
+		}
+		for _, settings := range *pool.BackendHTTPSettingsCollection {
+			if settings.Servers == nil {
+				continue
+			}
+			for _, server := range *settings.Servers {
+				servers[to.String(server.Address)] = server.Health
+			}
+		}
+	}
+	return servers
+}
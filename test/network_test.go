@@ -0,0 +1,63 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-09-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gruntwork-io/terratest/modules/azure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNSGPriorityConflicts(t *testing.T) {
+	defaults := []azure.NsgRuleSummary{
+		{Name: "AllowVnetInBound", Priority: 65000, Direction: "Inbound"},
+		{Name: "DenyAllInBound", Priority: 65500, Direction: "Inbound"},
+		{Name: "AllowVnetOutBound", Priority: 65000, Direction: "Outbound"},
+	}
+	rules := func(custom ...azure.NsgRuleSummary) []azure.NsgRuleSummary {
+		return append(append([]azure.NsgRuleSummary{}, defaults...), custom...)
+	}
+
+	// Confirm well-ordered rules pass, including an inbound and outbound rule sharing a priority
+	assert.Empty(t, nsgPriorityConflicts(rules(
+		azure.NsgRuleSummary{Name: "SSH", Priority: 1001, Direction: "Inbound"},
+		azure.NsgRuleSummary{Name: "HTTP", Priority: 1002, Direction: "Inbound"},
+		azure.NsgRuleSummary{Name: "AllowHTTPOut", Priority: 1001, Direction: "Outbound"},
+	)))
+
+	// Confirm a shared priority names both rules
+	assert.Equal(t, []string{"Inbound 1001 priority is shared by [HTTP SSH]"}, nsgPriorityConflicts(rules(
+		azure.NsgRuleSummary{Name: "SSH", Priority: 1001, Direction: "Inbound"},
+		azure.NsgRuleSummary{Name: "HTTP", Priority: 1001, Direction: "Inbound"},
+	)))
+
+	// Confirm a custom rule behind the defaults is reported
+	assert.Equal(t, []string{"Outbound rule LateDeny has priority 65001, not ahead of the default rules at 65000"}, nsgPriorityConflicts(rules(
+		azure.NsgRuleSummary{Name: "LateDeny", Priority: 65001, Direction: "Outbound"},
+	)))
+}
+
+func TestBackendServerHealth(t *testing.T) {
+	servers := func(servers ...network.ApplicationGatewayBackendHealthServer) *[]network.ApplicationGatewayBackendHealthHTTPSettings {
+		return &[]network.ApplicationGatewayBackendHealthHTTPSettings{{Servers: &servers}}
+	}
+	health := network.ApplicationGatewayBackendHealth{
+		BackendAddressPools: &[]network.ApplicationGatewayBackendHealthPool{
+			{BackendHTTPSettingsCollection: servers(
+				network.ApplicationGatewayBackendHealthServer{Address: to.StringPtr("10.0.1.4"), Health: network.Up},
+				network.ApplicationGatewayBackendHealthServer{Address: to.StringPtr("10.0.1.5"), Health: network.Down},
+			)},
+			{BackendHTTPSettingsCollection: nil},
+		},
+	}
+
+	// Confirm every server's health is collected across pools and settings
+	assert.Equal(t, map[string]network.ApplicationGatewayBackendHealthServerHealth{
+		"10.0.1.4": network.Up,
+		"10.0.1.5": network.Down,
+	}, backendServerHealth(health))
+
+	// Confirm a gateway that hasn't reported any pools yet has no servers
+	assert.Empty(t, backendServerHealth(network.ApplicationGatewayBackendHealth{}))
+}
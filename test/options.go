@@ -0,0 +1,180 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// moduleDir is the path to the Terraform module under test, relative to this package
+const moduleDir = "../"
+
+// defaultLabelPrefix is the labelPrefix the shared deployment uses
+const defaultLabelPrefix = "lian0138"
+
+// defaultRegion is the region variable's default in variables.tf
+const defaultRegion = "westus3"
+
+// defaultVMSize is the vm_size variable's default in variables.tf
+const defaultVMSize = "Standard_B1s"
+
+// defaultImageOffer and defaultImageSKU are the image_offer and image_sku variables' defaults in variables.tf
+const (
+	defaultImageOffer = "0001-com-ubuntu-server-jammy"
+	defaultImageSKU   = "22_04-lts-gen2"
+)
+
+// optionsRegion returns the region a deployment targets
+func optionsRegion(options *terraform.Options) string {
+	if region, ok := options.Vars["region"].(string); ok && region != "" {
+		return region
+	}
+	return defaultRegion
+}
+
+// optionsVMSize returns the VM size a deployment uses
+func optionsVMSize(options *terraform.Options) string {
+	if size, ok := options.Vars["vm_size"].(string); ok && size != "" {
+		return size
+	}
+	return defaultVMSize
+}
+
+// optionsImage returns the image offer and SKU a deployment uses
+func optionsImage(options *terraform.Options) (string, string) {
+	offer, sku := defaultImageOffer, defaultImageSKU
+	if value, ok := options.Vars["image_offer"].(string); ok && value != "" {
+		offer = value
+	}
+	if value, ok := options.Vars["image_sku"].(string); ok && value != "" {
+		sku = value
+	}
+	return offer, sku
+}
+
+// uniqueLabelPrefix returns a labelPrefix that won't collide with the shared deployment
+func uniqueLabelPrefix() string {
+	return defaultLabelPrefix + strings.ToLower(random.UniqueId())
+}
+
+// uniqueRGSuffix returns a resource_group_suffix derived from the test's name plus a random ID, so the
+// resource group is both unique and traceable to the test that created it
+func uniqueRGSuffix(t *testing.T) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToLower(t.Name()))
+	if len(name) > 30 {
+		name = name[:30]
+	}
+	return name + "-" + strings.ToLower(random.UniqueId())
+}
+
+// copyModule copies the module to a fresh temp folder so terraform runs don't share state
+func copyModule(t *testing.T) string {
+	dir, err := files.CopyTerraformFolderToTemp(moduleDir, "module")
+	require.NoError(t, err, "Failed to copy the module to a temp folder")
+	return dir
+}
+
+// mergeVars layers terraform variables, later maps overriding earlier ones
+func mergeVars(layers ...map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for _, layer := range layers {
+		for name, value := range layer {
+			merged[name] = value
+		}
+	}
+	return merged
+}
+
+// parseExtraVars parses EXTRA_TF_VARS_JSON, which must be a JSON object of variable names to values
+func parseExtraVars(value string) (map[string]interface{}, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	vars := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(value), &vars); err != nil {
+		return nil, fmt.Errorf("EXTRA_TF_VARS_JSON must be a JSON object of terraform variables: %w", err)
+	}
+	return vars, nil
+}
+
+// moduleVars builds the variables for a deployment, so any module variable can be set from the
+// environment without code changes. From lowest to highest precedence:
+//
+//   - defaults, the suite's inline values such as labelPrefix
+//   - EXTRA_TF_VARS_JSON, e.g. {"vm_size": "Standard_B2s"}
+//   - vars, the values a test sets because its assertions depend on them
+//
+// Overriding labelPrefix from the environment makes standalone deployments share names, so they
+// can no longer run side by side.
+func moduleVars(t *testing.T, defaults, vars map[string]interface{}) map[string]interface{} {
+	extra, err := parseExtraVars(os.Getenv("EXTRA_TF_VARS_JSON"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mergeVars(defaults, extra, vars)
+}
+
+// isolatedOptions returns options for a standalone deployment of the module in its own
+// temp folder and under a unique labelPrefix. vars are merged over the defaults as moduleVars describes.
+// The test is skipped when no Azure credentials are available.
+func isolatedOptions(t *testing.T, vars map[string]interface{}) *terraform.Options {
+	requireAzureCredentials(t)
+	timeTest(t)
+
+	return &terraform.Options{
+		TerraformDir: copyModule(t),
+		Vars:         moduleVars(t, map[string]interface{}{"labelPrefix": uniqueLabelPrefix()}, vars),
+	}
+}
+
+// childModuleOptions is like isolatedOptions, but the deployment calls a copy of the module as a child
+// module from a generated wrapper, so vars reach it through one level of indirection
+func childModuleOptions(t *testing.T, vars map[string]interface{}) *terraform.Options {
+	requireAzureCredentials(t)
+	timeTest(t)
+
+	dir, err := writeChildModuleE(copyModule(t), moduleVars(t, map[string]interface{}{"labelPrefix": uniqueLabelPrefix()}, vars))
+	require.NoError(t, err, "Failed to write the child module wrapper")
+	return &terraform.Options{TerraformDir: dir}
+}
+
+// remoteBackendOptions is like isolatedOptions, but the module copy keeps its state in backend's container
+// instead of a local file, under a key named for the deployment's resource group. It returns the backend with
+// that key set.
+func remoteBackendOptions(t *testing.T, vars map[string]interface{}, backend remoteBackend) (*terraform.Options, remoteBackend) {
+	options := isolatedOptions(t, vars)
+	require.NoError(t, writeBackendFileE(options.TerraformDir), "Failed to declare the azurerm backend")
+
+	backend.Key = moduleResourceGroupName(options.Vars) + ".tfstate"
+	options.BackendConfig = backend.backendConfig()
+	return options, backend
+}
+
+// planIsolated plans a standalone copy of the module with vars and returns the parsed plan
+func planIsolated(t *testing.T, vars map[string]interface{}) *terraform.PlanStruct {
+	return planStruct(t, isolatedOptions(t, vars))
+}
+
+// planStruct inits and plans options and returns the parsed plan. terraform show needs a saved plan, so
+// without a PlanFilePath one is written to the module's folder; options is left unchanged so later
+// applies don't pick the plan up.
+func planStruct(t *testing.T, options *terraform.Options) *terraform.PlanStruct {
+	planOptions := *options
+	if planOptions.PlanFilePath == "" {
+		planOptions.PlanFilePath = filepath.Join(options.TerraformDir, "tfplan")
+	}
+	return terraform.InitAndPlanAndShowWithStruct(t, &planOptions)
+}
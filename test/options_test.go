@@ -0,0 +1,46 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleVarsMergesExtraVarsJSON(t *testing.T) {
+	t.Setenv("EXTRA_TF_VARS_JSON", `{"vm_size": "Standard_B2s", "labelPrefix": "override", "nic_count": 2, "subnets": {"web": "10.0.1.0/24"}}`)
+
+	vars := moduleVars(t,
+		map[string]interface{}{"labelPrefix": "lian0138", "region": "canadacentral"},
+		map[string]interface{}{"vm_size": "Standard_B1ms"})
+
+	// Confirm the JSON overrides inline defaults, the test's own vars override the JSON, and other defaults survive
+	assert.Equal(t, "override", vars["labelPrefix"])
+	assert.Equal(t, "Standard_B1ms", vars["vm_size"])
+	assert.Equal(t, "canadacentral", vars["region"])
+	assert.Equal(t, float64(2), vars["nic_count"])
+	assert.Equal(t, map[string]interface{}{"web": "10.0.1.0/24"}, vars["subnets"])
+}
+
+func TestParseExtraVarsRejectsInvalidJSON(t *testing.T) {
+	vars, err := parseExtraVars("")
+	require.NoError(t, err)
+	assert.Empty(t, vars)
+
+	for _, invalid := range []string{`{"vm_size": `, `["vm_size"]`, `"Standard_B2s"`} {
+		_, err := parseExtraVars(invalid)
+		require.Error(t, err, "Accepted invalid EXTRA_TF_VARS_JSON %s", invalid)
+		assert.Contains(t, err.Error(), "EXTRA_TF_VARS_JSON must be a JSON object")
+	}
+}
+
+func TestUniqueRGSuffix(t *testing.T) {
+	t.Run("Sub_Test/With Spaces", func(t *testing.T) {
+		suffix := uniqueRGSuffix(t)
+
+		// Confirm the suffix is traceable to the test, unique and accepted by the module's validation
+		assert.Regexp(t, `^testuniquergsuffixsubtestwiths-[a-z0-9]{6}$`, suffix)
+		assert.NotEqual(t, suffix, uniqueRGSuffix(t), "Suffixes repeated")
+		assert.LessOrEqual(t, len(suffix), 40)
+	})
+}
@@ -0,0 +1,184 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// terraformOutputAllE reads every terraform output; unit tests swap it for a stub
+var terraformOutputAllE = terraform.OutputAllE
+
+// Outputs holds the module's outputs. The output tag names the terraform output each field is read from.
+type Outputs struct {
+	ResourceGroupName          string            `output:"resource_group_name"`
+	VMName                     string            `output:"vm_name"`
+	NICName                    string            `output:"nic_name"`
+	PublicIP                   string            `output:"public_ip"`
+	PublicIPName               string            `output:"public_ip_name"`
+	MonitorAgentExtensionName  string            `output:"monitor_agent_extension_name"`
+	NSGName                    string            `output:"nsg_name"`
+	RestrictEgress             bool              `output:"restrict_egress"`
+	VNetName                   string            `output:"vnet_name"`
+	BastionName                string            `output:"bastion_name"`
+	VMSize                     string            `output:"vm_size"`
+	AdminUsername              string            `output:"admin_username"`
+	OSDiskCaching              string            `output:"os_disk_caching"`
+	CustomDataMarker           string            `output:"custom_data_marker"`
+	NICCount                   int               `output:"nic_count"`
+	SecureBootEnabled          bool              `output:"secure_boot_enabled"`
+	VTPMEnabled                bool              `output:"vtpm_enabled"`
+	IndexMarker                string            `output:"index_marker"`
+	SubnetIDs                  map[string]string `output:"subnet_ids"`
+	NICSubnetName              string            `output:"nic_subnet_name"`
+	PlanName                   string            `output:"plan_name"`
+	PlanPublisher              string            `output:"plan_publisher"`
+	PlanProduct                string            `output:"plan_product"`
+	DiskControllerType         string            `output:"disk_controller_type"`
+	PrivateDNSZoneName         string            `output:"private_dns_zone_name"`
+	PrivateDNSLinkName         string            `output:"private_dns_link_name"`
+	PrivateDNSAutoRegistration bool              `output:"private_dns_auto_registration"`
+	HTTPSEnabled               bool              `output:"https_enabled"`
+	VMID                       string            `output:"vm_id"`
+	PrivateEndpointPolicies    bool              `output:"private_endpoint_policies_enabled"`
+	HyperVGeneration           string            `output:"hyper_v_generation"`
+	Zone                       string            `output:"zone"`
+	PublicIPEnabled            bool              `output:"public_ip_enabled"`
+	PublicIPAllocation         string            `output:"public_ip_allocation"`
+	PublicIPSKU                string            `output:"public_ip_sku"`
+	AutoShutdownTime           string            `output:"auto_shutdown_time"`
+	AutoShutdownScheduleName   string            `output:"auto_shutdown_schedule_name"`
+	MetricsEnabled             bool              `output:"metrics_enabled"`
+	GzipEnabled                bool              `output:"gzip_enabled"`
+	SharedImageID              string            `output:"shared_image_id"`
+	PublicIPIdleTimeout        int               `output:"public_ip_idle_timeout"`
+	LoadBalancerName           string            `output:"load_balancer_name"`
+	LoadBalancerIP             string            `output:"load_balancer_ip"`
+	AppGatewayName             string            `output:"app_gateway_name"`
+	AppGatewayIP               string            `output:"app_gateway_ip"`
+	NSGID                      string            `output:"nsg_id"`
+	NSGDiagnosticSettingName   string            `output:"nsg_diagnostic_setting_name"`
+	NSGLogCategories           []string          `output:"nsg_log_categories"`
+	LicenseType                string            `output:"license_type"`
+	WebServerLogs              map[string]string `output:"web_server_logs"`
+	EphemeralOSDisk            bool              `output:"ephemeral_os_disk"`
+	Location                   string            `output:"location"`
+	ProximityPlacementGroupID  string            `output:"proximity_placement_group_id"`
+	SecondaryPrivateIP         string            `output:"secondary_private_ip"`
+	DataDisks                  []DataDisk        `output:"data_disks"`
+	DiskResourceGroupName      string            `output:"disk_resource_group_name"`
+	WebServerWorkers           int               `output:"web_server_workers"`
+	DNSRecordFQDN              string            `output:"dns_record_fqdn"`
+	OSType                     string            `output:"os_type"`
+	Architecture               string            `output:"architecture"`
+}
+
+// DataDisk is an attached data disk as the data_disks output describes it
+type DataDisk struct {
+	Name    string `json:"name"`
+	LUN     int    `json:"lun"`
+	Caching string `json:"caching"`
+}
+
+// loadOutputs reads the module's outputs into an Outputs, failing the test if any are missing
+func loadOutputs(t testing.TestingT, options *terraform.Options) Outputs {
+	out, err := loadOutputsE(t, options)
+	require.NoError(t, err, "Failed to load terraform outputs")
+	return out
+}
+
+// loadOutputsE reads the module's outputs into an Outputs
+func loadOutputsE(t testing.TestingT, options *terraform.Options) (Outputs, error) {
+	all, err := terraformOutputAllE(t, options)
+	if err != nil {
+		return Outputs{}, err
+	}
+	return outputsFromMap(all)
+}
+
+// outputsFromMap populates an Outputs from decoded `terraform output -json` values.
+// Every tagged field must be present; extra outputs are ignored.
+func outputsFromMap(all map[string]interface{}) (Outputs, error) {
+	var out Outputs
+	missing := []string{}
+
+	fields := reflect.ValueOf(&out).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		name := fields.Type().Field(i).Tag.Get("output")
+		value, ok := all[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+
+		// Round-trip through JSON so non-string outputs decode into their field types
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return out, fmt.Errorf("output %s could not be encoded: %w", name, err)
+		}
+		if err := json.Unmarshal(raw, fields.Field(i).Addr().Interface()); err != nil {
+			return out, fmt.Errorf("output %s has an unexpected type: %w", name, err)
+		}
+	}
+
+	if len(missing) > 0 {
+		return out, fmt.Errorf("missing terraform outputs: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// changedOutputs lists the outputs whose values differ between two reads of `terraform output`, including
+// ones added or removed, sorted by name. Outputs named in ignore are left out.
+func changedOutputs(before, after map[string]interface{}, ignore ...string) []string {
+	names := map[string]bool{}
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	changed := []string{}
+	for name := range names {
+		if slices.Contains(ignore, name) {
+			continue
+		}
+		previous, hadBefore := before[name]
+		current, hasAfter := after[name]
+		if hadBefore != hasAfter || !reflect.DeepEqual(previous, current) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// assertOutputIn checks that the named output is one of allowed, for outputs with more than one valid
+// representation such as a region Azure may report as "westus3" or "West US 3". It returns whether it matched.
+func assertOutputIn(t testing.TestingT, options *terraform.Options, name string, allowed []string) bool {
+	all, err := terraformOutputAllE(t, options)
+	require.NoError(t, err, "Failed to read terraform outputs")
+	value, ok := all[name]
+	if !assert.True(t, ok, "No %s output", name) {
+		return false
+	}
+	return assert.Contains(t, allowed, fmt.Sprint(value), "Output %s is %q, expected one of %q", name, value, allowed)
+}
+
+// regionNames returns the forms Azure may report region in: as given, and as the lowercase name without
+// spaces ("West US 3" becomes "westus3")
+func regionNames(region string) []string {
+	canonical := strings.ToLower(strings.ReplaceAll(region, " ", ""))
+	if canonical == region {
+		return []string{region}
+	}
+	return []string{region, canonical}
+}
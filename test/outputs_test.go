@@ -0,0 +1,260 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubOutputs replaces the output reader with a fixed output map for the duration of the test
+func stubOutputs(t *testing.T, all map[string]interface{}) {
+	original := terraformOutputAllE
+	terraformOutputAllE = func(terratesting.TestingT, *terraform.Options) (map[string]interface{}, error) {
+		return all, nil
+	}
+	t.Cleanup(func() { terraformOutputAllE = original })
+}
+
+// sampleOutputs returns a complete output map as `terraform output -json` would decode it
+func sampleOutputs() map[string]interface{} {
+	return map[string]interface{}{
+		"resource_group_name":          "lian0138-A05-RG",
+		"vm_name":                      "lian0138A05VM",
+		"nic_name":                     "lian0138A05Nic",
+		"public_ip":                    "20.1.2.3",
+		"public_ip_name":               "lian0138A05PublicIP",
+		"monitor_agent_extension_name": "",
+		"nsg_name":                     "lian0138A05SG",
+		"restrict_egress":              true,
+		"vnet_name":                    "lian0138A05Vnet",
+		"bastion_name":                 "",
+		"vm_size":                      "Standard_B1s",
+		"admin_username":               "azureadmin",
+		"os_disk_caching":              "ReadWrite",
+		"custom_data_marker":           "/var/log/a05-init.done",
+		"nic_count":                    float64(1),
+		"secure_boot_enabled":          false,
+		"vtpm_enabled":                 false,
+		"index_marker":                 "Hello from lian0138",
+		"subnet_ids": map[string]interface{}{
+			"web": "/subscriptions/sub/resourceGroups/lian0138-A05-RG/providers/Microsoft.Network/virtualNetworks/lian0138A05Vnet/subnets/lian0138A05Subnet",
+		},
+		"nic_subnet_name":                   "web",
+		"plan_name":                         "",
+		"plan_publisher":                    "",
+		"plan_product":                      "",
+		"disk_controller_type":              "SCSI",
+		"private_dns_zone_name":             "",
+		"private_dns_link_name":             "",
+		"private_dns_auto_registration":     false,
+		"https_enabled":                     false,
+		"vm_id":                             "/subscriptions/sub/resourceGroups/lian0138-A05-RG/providers/Microsoft.Compute/virtualMachines/lian0138A05VM",
+		"private_endpoint_policies_enabled": true,
+		"hyper_v_generation":                "V2",
+		"zone":                              "",
+		"public_ip_enabled":                 true,
+		"public_ip_allocation":              "Dynamic",
+		"public_ip_sku":                     "Basic",
+		"auto_shutdown_time":                "1900",
+		"auto_shutdown_schedule_name":       "shutdown-computevm-lian0138A05VM",
+		"metrics_enabled":                   false,
+		"gzip_enabled":                      true,
+		"shared_image_id":                   "",
+		"public_ip_idle_timeout":            float64(4),
+		"load_balancer_name":                "",
+		"load_balancer_ip":                  "",
+		"app_gateway_name":                  "",
+		"app_gateway_ip":                    "",
+		"nsg_id":                            "/subscriptions/sub/resourceGroups/lian0138-A05-RG/providers/Microsoft.Network/networkSecurityGroups/lian0138A05SG",
+		"nsg_diagnostic_setting_name":       "",
+		"nsg_log_categories":                []interface{}{},
+		"license_type":                      "",
+		"web_server_logs": map[string]interface{}{
+			"access_log":       "/var/log/apache2/access.log",
+			"error_log":        "/var/log/apache2/error.log",
+			"logrotate_config": "/etc/logrotate.d/apache2",
+		},
+		"ephemeral_os_disk":            false,
+		"location":                     "westus3",
+		"proximity_placement_group_id": "",
+		"secondary_private_ip":         "",
+		"data_disks": []interface{}{
+			map[string]interface{}{"name": "lian0138A05DataDisk0", "lun": float64(0), "caching": "ReadWrite"},
+		},
+		"disk_resource_group_name": "lian0138-A05-RG",
+		"web_server_workers":       float64(2),
+		"dns_record_fqdn":          "",
+		"os_type":                  "linux",
+		"architecture":             "x64",
+	}
+}
+
+func TestLoadOutputs(t *testing.T) {
+	stubOutputs(t, sampleOutputs())
+
+	out := loadOutputs(t, &terraform.Options{})
+
+	assert.Equal(t, "lian0138-A05-RG", out.ResourceGroupName)
+	assert.Equal(t, "lian0138A05VM", out.VMName)
+	assert.Equal(t, "lian0138A05Nic", out.NICName)
+	assert.Equal(t, "20.1.2.3", out.PublicIP)
+	assert.Equal(t, "lian0138A05PublicIP", out.PublicIPName)
+	assert.Empty(t, out.MonitorAgentExtensionName)
+	assert.Equal(t, "lian0138A05SG", out.NSGName)
+	assert.True(t, out.RestrictEgress)
+	assert.Equal(t, "lian0138A05Vnet", out.VNetName)
+	assert.Empty(t, out.BastionName)
+	assert.Equal(t, "Standard_B1s", out.VMSize)
+	assert.Equal(t, "azureadmin", out.AdminUsername)
+	assert.Equal(t, "ReadWrite", out.OSDiskCaching)
+	assert.Equal(t, "/var/log/a05-init.done", out.CustomDataMarker)
+	assert.Equal(t, 1, out.NICCount)
+	assert.False(t, out.SecureBootEnabled)
+	assert.False(t, out.VTPMEnabled)
+	assert.Equal(t, "Hello from lian0138", out.IndexMarker)
+	assert.Equal(t, map[string]string{
+		"web": "/subscriptions/sub/resourceGroups/lian0138-A05-RG/providers/Microsoft.Network/virtualNetworks/lian0138A05Vnet/subnets/lian0138A05Subnet",
+	}, out.SubnetIDs)
+	assert.Equal(t, "web", out.NICSubnetName)
+	assert.Empty(t, out.PlanName)
+	assert.Empty(t, out.PlanPublisher)
+	assert.Empty(t, out.PlanProduct)
+	assert.Equal(t, "SCSI", out.DiskControllerType)
+	assert.Empty(t, out.PrivateDNSZoneName)
+	assert.Empty(t, out.PrivateDNSLinkName)
+	assert.False(t, out.PrivateDNSAutoRegistration)
+	assert.False(t, out.HTTPSEnabled)
+	assert.Equal(t, "/subscriptions/sub/resourceGroups/lian0138-A05-RG/providers/Microsoft.Compute/virtualMachines/lian0138A05VM", out.VMID)
+	assert.True(t, out.PrivateEndpointPolicies)
+	assert.Equal(t, "V2", out.HyperVGeneration)
+	assert.Empty(t, out.Zone)
+	assert.True(t, out.PublicIPEnabled)
+	assert.Equal(t, "Dynamic", out.PublicIPAllocation)
+	assert.Equal(t, "1900", out.AutoShutdownTime)
+	assert.Equal(t, "shutdown-computevm-lian0138A05VM", out.AutoShutdownScheduleName)
+	assert.False(t, out.MetricsEnabled)
+	assert.True(t, out.GzipEnabled)
+	assert.Empty(t, out.SharedImageID)
+	assert.Equal(t, 4, out.PublicIPIdleTimeout)
+	assert.Empty(t, out.LoadBalancerName)
+	assert.Empty(t, out.LoadBalancerIP)
+	assert.Empty(t, out.AppGatewayName)
+	assert.Empty(t, out.AppGatewayIP)
+	assert.Equal(t, "/subscriptions/sub/resourceGroups/lian0138-A05-RG/providers/Microsoft.Network/networkSecurityGroups/lian0138A05SG", out.NSGID)
+	assert.Empty(t, out.NSGDiagnosticSettingName)
+	assert.Empty(t, out.NSGLogCategories)
+	assert.Empty(t, out.LicenseType)
+	assert.Equal(t, "/var/log/apache2/access.log", out.WebServerLogs["access_log"])
+	assert.False(t, out.EphemeralOSDisk)
+	assert.Equal(t, "westus3", out.Location)
+	assert.Empty(t, out.ProximityPlacementGroupID)
+	assert.Empty(t, out.SecondaryPrivateIP)
+	assert.Equal(t, []DataDisk{{Name: "lian0138A05DataDisk0", LUN: 0, Caching: "ReadWrite"}}, out.DataDisks)
+	assert.Equal(t, "lian0138-A05-RG", out.DiskResourceGroupName)
+	assert.Equal(t, 2, out.WebServerWorkers)
+	assert.Empty(t, out.DNSRecordFQDN)
+	assert.Equal(t, "linux", out.OSType)
+	assert.Equal(t, "x64", out.Architecture)
+	assert.Equal(t, "Basic", out.PublicIPSKU)
+}
+
+func TestLoadOutputsReportsMissing(t *testing.T) {
+	all := sampleOutputs()
+	delete(all, "vm_name")
+	delete(all, "nic_name")
+	stubOutputs(t, all)
+
+	_, err := loadOutputsE(t, &terraform.Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vm_name")
+	assert.Contains(t, err.Error(), "nic_name")
+}
+
+func TestLoadOutputsRejectsWrongType(t *testing.T) {
+	all := sampleOutputs()
+	all["vm_name"] = []interface{}{"not", "a", "string"}
+	stubOutputs(t, all)
+
+	_, err := loadOutputsE(t, &terraform.Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vm_name")
+}
+
+func TestChangedOutputs(t *testing.T) {
+	before := sampleOutputs()
+	after := sampleOutputs()
+	after["public_ip"] = "20.0.0.2"
+	after["vm_id"] = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/other"
+	delete(after, "zone")
+
+	// Confirm changed and removed outputs are reported and ignored ones are not
+	assert.Equal(t, []string{"vm_id", "zone"}, changedOutputs(before, after, "public_ip"))
+	assert.Empty(t, changedOutputs(before, sampleOutputs()))
+}
+
+// failureRecorder captures assertion failures so a test can check an assertion fails
+type failureRecorder struct {
+	*testing.T
+	failures []string
+}
+
+func (r *failureRecorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertOutputIn(t *testing.T) {
+	stubOutputs(t, sampleOutputs())
+	options := &terraform.Options{TerraformDir: "../"}
+
+	t.Run("Match", func(t *testing.T) {
+		recorder := &failureRecorder{T: t}
+		assert.True(t, assertOutputIn(recorder, options, "location", regionNames("West US 3")))
+		assert.Empty(t, recorder.failures)
+	})
+
+	t.Run("NoMatch", func(t *testing.T) {
+		recorder := &failureRecorder{T: t}
+
+		// Confirm the failure names the output, its value and what was allowed
+		assert.False(t, assertOutputIn(recorder, options, "vm_size", []string{"Standard_B2s", "Standard_D2s_v5"}))
+		require.Len(t, recorder.failures, 1)
+		assert.Contains(t, recorder.failures[0], `Output vm_size is "Standard_B1s"`)
+		assert.Contains(t, recorder.failures[0], "Standard_D2s_v5")
+	})
+}
+
+func TestRegionNames(t *testing.T) {
+	assert.Equal(t, []string{"westus3"}, regionNames("westus3"))
+	assert.Equal(t, []string{"West US 3", "westus3"}, regionNames("West US 3"))
+}
+
+func TestConcurrentOutputReads(t *testing.T) {
+	stubOutputs(t, sampleOutputs())
+	options := &terraform.Options{TerraformDir: "../"}
+
+	// Read the shared outputs from several goroutines at once, as parallel read-only tests do; run with -race.
+	// loadOutputsE is used because require can't stop the test from another goroutine.
+	const readers = 8
+	results := make([]Outputs, readers)
+	errs := make([]error, readers)
+	var wg sync.WaitGroup
+	for i := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = loadOutputsE(t, options)
+		}()
+	}
+	wg.Wait()
+
+	// Confirm every reader decoded the same values
+	for i := range readers {
+		require.NoError(t, errs[i], "Reader %d failed", i)
+		assert.Equal(t, results[0], results[i], "Reader %d decoded different outputs", i)
+	}
+}
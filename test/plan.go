@@ -0,0 +1,76 @@
+package test
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// update rewrites golden files with the current results instead of comparing against them
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// plannedChange is the stable part of a planned resource change: what is created and how
+type plannedChange struct {
+	Address string   `json:"address"`
+	Type    string   `json:"type"`
+	Actions []string `json:"actions"`
+}
+
+// normalizePlan reduces a plan to its managed resource changes sorted by address. Only the address, type and
+// actions are kept, so IDs, timestamps and computed values never reach the comparison, and labelPrefix
+// (which carries a random suffix in isolated runs) is replaced with a placeholder.
+func normalizePlan(plan *tfjson.Plan, labelPrefix string) []plannedChange {
+	changes := []plannedChange{}
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode != tfjson.ManagedResourceMode || rc.Change == nil {
+			continue
+		}
+		address := rc.Address
+		if labelPrefix != "" {
+			address = strings.ReplaceAll(address, labelPrefix, "<labelPrefix>")
+		}
+		actions := []string{}
+		for _, action := range rc.Change.Actions {
+			actions = append(actions, string(action))
+		}
+		changes = append(changes, plannedChange{Address: address, Type: rc.Type, Actions: actions})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Address < changes[j].Address })
+	return changes
+}
+
+// assertGolden compares value, serialized as indented JSON, with testdata/name. Run with -update to
+// rewrite the file after an intended change.
+func assertGolden(t *testing.T, name string, value interface{}) {
+	t.Helper()
+	actual, err := json.MarshalIndent(value, "", "  ")
+	require.NoError(t, err, "Failed to serialize %s", name)
+	actual = append(actual, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755), "Failed to create testdata")
+		require.NoError(t, os.WriteFile(path, actual, 0o644), "Failed to update golden file %s", path)
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "Failed to read golden file %s (run with -update to create it)", path)
+	assert.Equal(t, string(expected), string(actual), "Result differs from %s; run with -update if the change is intended", path)
+}
+
+// planChanges plans options and returns the normalized changes
+func planChanges(t *testing.T, options *terraform.Options) []plannedChange {
+	plan := planStruct(t, options)
+	labelPrefix, _ := options.Vars["labelPrefix"].(string)
+	return normalizePlan(&plan.RawPlan, labelPrefix)
+}
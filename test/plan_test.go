@@ -0,0 +1,37 @@
+package test
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePlan(t *testing.T) {
+	plan := &tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		{
+			Address: "azurerm_virtual_network.vnet",
+			Mode:    tfjson.ManagedResourceMode,
+			Type:    "azurerm_virtual_network",
+			Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionCreate}, After: map[string]interface{}{"id": "abc"}},
+		},
+		{
+			Address: "data.cloudinit_config.init",
+			Mode:    tfjson.DataResourceMode,
+			Type:    "cloudinit_config",
+			Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionRead}},
+		},
+		{
+			Address: `azurerm_resource_group.rg["lian0138x1y2z3"]`,
+			Mode:    tfjson.ManagedResourceMode,
+			Type:    "azurerm_resource_group",
+			Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}},
+		},
+	}}
+
+	// Confirm data sources are dropped, the prefix is masked and changes are sorted by address
+	assert.Equal(t, []plannedChange{
+		{Address: `azurerm_resource_group.rg["<labelPrefix>"]`, Type: "azurerm_resource_group", Actions: []string{"delete", "create"}},
+		{Address: "azurerm_virtual_network.vnet", Type: "azurerm_virtual_network", Actions: []string{"create"}},
+	}, normalizePlan(plan, "lian0138x1y2z3"))
+}
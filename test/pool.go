@@ -0,0 +1,35 @@
+package test
+
+import "sync"
+
+// forEachBounded calls fn for every item with at most limit calls in flight.
+// A failing item doesn't stop the others; errors are returned keyed by item.
+func forEachBounded(items []string, limit int, fn func(item string) error) map[string]error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	var (
+		semaphore = make(chan struct{}, limit)
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		errs      = map[string]error{}
+	)
+	for _, item := range items {
+		wg.Add(1)
+		go func(item string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			if err := fn(item); err != nil {
+				mu.Lock()
+				errs[item] = err
+				mu.Unlock()
+			}
+		}(item)
+	}
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEachBoundedLimitsConcurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	items := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	errs := forEachBounded(items, 3, func(item string) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := peak.Load()
+			if current <= seen || peak.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	assert.Empty(t, errs)
+	assert.LessOrEqual(t, peak.Load(), int32(3), "More workers ran at once than the limit allows")
+}
+
+func TestForEachBoundedCollectsErrors(t *testing.T) {
+	var calls atomic.Int32
+
+	errs := forEachBounded([]string{"ok", "bad", "worse"}, 2, func(item string) error {
+		calls.Add(1)
+		if item != "ok" {
+			return errors.New(item + " failed")
+		}
+		return nil
+	})
+
+	// Confirm a failure doesn't abort the rest of the batch
+	assert.Equal(t, int32(3), calls.Load(), "Not every item was processed")
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs["bad"], "bad failed")
+	assert.EqualError(t, errs["worse"], "worse failed")
+}
@@ -0,0 +1,234 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// servicePrincipalEnvVars are the variables service principal auth needs
+var servicePrincipalEnvVars = []string{"ARM_CLIENT_ID", "ARM_CLIENT_SECRET", "ARM_TENANT_ID", "ARM_SUBSCRIPTION_ID"}
+
+// azureCLILoggedIn reports whether the Azure CLI has an active login; unit tests swap it for a stub
+var azureCLILoggedIn = func() bool {
+	return exec.Command("az", "account", "show").Run() == nil
+}
+
+// missingAzureCredentials explains why neither service principal nor CLI auth is usable,
+// or returns "" when one of them is
+func missingAzureCredentials() string {
+	missing := []string{}
+	for _, name := range servicePrincipalEnvVars {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 || azureCLILoggedIn() {
+		return ""
+	}
+	return fmt.Sprintf("no Azure credentials: service principal auth is missing %s, and `az account show` failed (run `az login` for CLI auth)",
+		strings.Join(missing, ", "))
+}
+
+// authEnvironment checks the prerequisites of an explicit AZURE_AUTH_METHOD and returns the environment
+// that makes both terraform's azurerm provider (ARM_*) and terratest's SDK clients (AZURE_*) use it:
+//
+//   - sp: a service principal from ARM_CLIENT_ID, ARM_CLIENT_SECRET, ARM_TENANT_ID and ARM_SUBSCRIPTION_ID (CI)
+//   - cli: the Azure CLI's login (local development)
+//   - msi: the managed identity whose client ID is ARM_CLIENT_ID, with ARM_TENANT_ID and ARM_SUBSCRIPTION_ID (self-hosted runners)
+func authEnvironment(method string) (map[string]string, error) {
+	missing := func(names ...string) error {
+		unset := []string{}
+		for _, name := range names {
+			if os.Getenv(name) == "" {
+				unset = append(unset, name)
+			}
+		}
+		if len(unset) == 0 {
+			return nil
+		}
+		return fmt.Errorf("AZURE_AUTH_METHOD=%s requires %s", method, strings.Join(unset, ", "))
+	}
+
+	switch method {
+	case "sp":
+		if err := missing(servicePrincipalEnvVars...); err != nil {
+			return nil, err
+		}
+		return map[string]string{
+			"AZURE_CLIENT_ID":     os.Getenv("ARM_CLIENT_ID"),
+			"AZURE_CLIENT_SECRET": os.Getenv("ARM_CLIENT_SECRET"),
+			"AZURE_TENANT_ID":     os.Getenv("ARM_TENANT_ID"),
+		}, nil
+	case "cli":
+		// The SDK prefers AZURE_CLIENT_ID and AZURE_TENANT_ID over the CLI whenever both are set
+		if os.Getenv("AZURE_CLIENT_ID") != "" && os.Getenv("AZURE_TENANT_ID") != "" {
+			return nil, fmt.Errorf("AZURE_AUTH_METHOD=cli, but AZURE_CLIENT_ID and AZURE_TENANT_ID are set and would be used instead; unset them")
+		}
+		if !azureCLILoggedIn() {
+			return nil, fmt.Errorf("AZURE_AUTH_METHOD=cli, but `az account show` failed; run `az login`")
+		}
+		return map[string]string{"ARM_USE_CLI": "true"}, nil
+	case "msi":
+		if err := missing("ARM_CLIENT_ID", "ARM_TENANT_ID", "ARM_SUBSCRIPTION_ID"); err != nil {
+			return nil, err
+		}
+		// With a secret set, both terraform and the SDK would authenticate as a service principal instead
+		if os.Getenv("ARM_CLIENT_SECRET") != "" || os.Getenv("AZURE_CLIENT_SECRET") != "" {
+			return nil, fmt.Errorf("AZURE_AUTH_METHOD=msi, but a client secret is set and would be used instead; unset ARM_CLIENT_SECRET and AZURE_CLIENT_SECRET")
+		}
+		return map[string]string{
+			"ARM_USE_MSI":     "true",
+			"AZURE_CLIENT_ID": os.Getenv("ARM_CLIENT_ID"),
+			"AZURE_TENANT_ID": os.Getenv("ARM_TENANT_ID"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown AZURE_AUTH_METHOD %q, expected cli, sp or msi", method)
+	}
+}
+
+// configureAuth applies the AZURE_AUTH_METHOD environment for the rest of the run, failing the test
+// immediately when the method's prerequisites are missing rather than leaving it to a vague auth error
+func configureAuth(t *testing.T) {
+	t.Helper()
+	env, err := authEnvironment(os.Getenv("AZURE_AUTH_METHOD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+}
+
+// offlineOnly is set by validate_test.go when built with `-tags validate`, so that run never touches Azure
+// whatever -run selects
+var offlineOnly bool
+
+// requireAzureCredentials skips the test unless Azure credentials are available, so a missing
+// credential is reported up front instead of as a deep SDK or provider error. An explicit
+// AZURE_AUTH_METHOD is configured instead, failing rather than skipping if it can't be used.
+// Every test that deploys or calls Azure comes through here, so it also skips them in offline runs.
+func requireAzureCredentials(t *testing.T) {
+	t.Helper()
+	if offlineOnly {
+		t.Skip("Skipping: built with -tags validate, which runs only the offline checks")
+	}
+	if os.Getenv("AZURE_AUTH_METHOD") != "" {
+		configureAuth(t)
+		return
+	}
+	if reason := missingAzureCredentials(); reason != "" {
+		t.Skip("Skipping: " + reason)
+	}
+}
+
+// requireTerraformCLI skips the test unless the terraform CLI is on the PATH
+func requireTerraformCLI(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("terraform"); err != nil {
+		t.Skip("Skipping: the terraform CLI is not installed or not on the PATH")
+	}
+}
+
+// requireFullProfile skips slow or disruptive tests unless TEST_PROFILE is "full"
+func requireFullProfile(t *testing.T) {
+	t.Helper()
+	if os.Getenv("TEST_PROFILE") != "full" {
+		t.Skip("Skipping: only runs with TEST_PROFILE=full")
+	}
+}
+
+// requireWindowsTests skips tests that deploy the Windows variant unless WINDOWS_TESTS is set, as they need
+// a Windows Server image and a larger VM than the Linux labs
+func requireWindowsTests(t *testing.T) {
+	t.Helper()
+	if !envFlag("WINDOWS_TESTS") {
+		t.Skip("Skipping: only runs with WINDOWS_TESTS=true")
+	}
+}
+
+// Quota lookups; unit tests swap them for stubs
+var (
+	computeUsagesE = listComputeUsagesE
+	vmSizeFamilyE  = getVMSizeFamilyE
+)
+
+// regionalVCPUQuota is the usage name of the subscription's total vCPU quota in a region
+const regionalVCPUQuota = "cores"
+
+// vcpuHeadroomE checks that both the size family's and the region's vCPU quotas have room for needed more vCPUs
+func vcpuHeadroomE(usages []compute.Usage, family string, needed int) error {
+	for _, quota := range []string{family, regionalVCPUQuota} {
+		found := false
+		for _, usage := range usages {
+			if usage.Name == nil || !strings.EqualFold(to.String(usage.Name.Value), quota) {
+				continue
+			}
+			found = true
+			available := to.Int64(usage.Limit) - int64(to.Int32(usage.CurrentValue))
+			if int64(needed) > available {
+				return fmt.Errorf("vCPU quota %s is exhausted: need %d vCPUs, have %d available (%d of %d used)",
+					quota, needed, available, to.Int32(usage.CurrentValue), to.Int64(usage.Limit))
+			}
+		}
+		if !found {
+			return fmt.Errorf("no %s vCPU quota found in the subscription's usage", quota)
+		}
+	}
+	return nil
+}
+
+// checkVCPUQuotaE checks the subscription has the vCPUs for one VM of vmSize in location
+func checkVCPUQuotaE(subscriptionID, location, vmSize string) error {
+	location = strings.ToLower(strings.ReplaceAll(location, " ", ""))
+	family, vcpus, err := vmSizeFamilyE(vmSize, location, subscriptionID)
+	if err != nil {
+		return err
+	}
+	usages, err := computeUsagesE(location, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if err := vcpuHeadroomE(usages, family, vcpus); err != nil {
+		return fmt.Errorf("%s in %s: %w", vmSize, location, err)
+	}
+	return nil
+}
+
+// checkVCPUQuota fails the test before apply when the subscription lacks the vCPU quota for the VM, instead of
+// leaving it to a quota error partway through the apply. Parallel classroom deployments exhaust quotas quickly.
+func checkVCPUQuota(t *testing.T, subscriptionID, location, vmSize string) {
+	t.Helper()
+	if err := checkVCPUQuotaE(subscriptionID, location, vmSize); err != nil {
+		t.Fatalf("vCPU quota preflight failed: %v", err)
+	}
+}
+
+// checkNameCollisionE checks no resource group already has the name the deployment would create. A fresh
+// deployment that finds one would fail partway through the apply with azurerm's "already exists" error, and
+// a deployment in a shared subscription could collide with someone else's resources.
+func checkNameCollisionE(subscriptionID, resourceGroupName string) error {
+	exists, err := azureAPI.ResourceGroupExists(resourceGroupName, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("resource group %s already exists; delete it, or pick another labelPrefix or resource_group_suffix", resourceGroupName)
+	}
+	return nil
+}
+
+// checkNameCollision fails the test before a fresh deployment's apply when its resource group name is taken.
+// Only call it for deployments without state, which would otherwise adopt nothing and fail on the create.
+func checkNameCollision(t *testing.T, subscriptionID string, options *terraform.Options) {
+	t.Helper()
+	if err := checkNameCollisionE(subscriptionID, moduleResourceGroupName(options.Vars)); err != nil {
+		t.Fatalf("Name collision preflight failed: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAzureCLI makes the CLI login check report loggedIn for the duration of the test
+func stubAzureCLI(t *testing.T, loggedIn bool) {
+	original := azureCLILoggedIn
+	azureCLILoggedIn = func() bool { return loggedIn }
+	t.Cleanup(func() { azureCLILoggedIn = original })
+}
+
+// skippedBy runs check in a subtest and reports whether it skipped
+func skippedBy(t *testing.T, check func(*testing.T)) bool {
+	skipped := false
+	t.Run("check", func(t *testing.T) {
+		defer func() { skipped = t.Skipped() }()
+		check(t)
+	})
+	return skipped
+}
+
+func TestRequireAzureCredentialsSkipsWithoutCredentials(t *testing.T) {
+	t.Setenv("AZURE_AUTH_METHOD", "")
+	stubAzureCLI(t, false)
+	for _, name := range servicePrincipalEnvVars {
+		t.Setenv(name, "")
+	}
+	t.Setenv("ARM_CLIENT_ID", "00000000-0000-0000-0000-000000000000")
+
+	// Confirm the message names exactly the missing variables
+	reason := missingAzureCredentials()
+	assert.Contains(t, reason, "ARM_CLIENT_SECRET, ARM_TENANT_ID, ARM_SUBSCRIPTION_ID")
+	assert.NotContains(t, reason, "ARM_CLIENT_ID")
+	assert.True(t, skippedBy(t, requireAzureCredentials), "Test was not skipped without credentials")
+}
+
+func TestRequireAzureCredentialsAcceptsServicePrincipal(t *testing.T) {
+	setOfflineOnly(t, false)
+	t.Setenv("AZURE_AUTH_METHOD", "")
+	stubAzureCLI(t, false)
+	for _, name := range servicePrincipalEnvVars {
+		t.Setenv(name, "set")
+	}
+
+	assert.False(t, skippedBy(t, requireAzureCredentials), "Test was skipped with complete service principal credentials")
+}
+
+func TestRequireAzureCredentialsAcceptsCLILogin(t *testing.T) {
+	setOfflineOnly(t, false)
+	t.Setenv("AZURE_AUTH_METHOD", "")
+	stubAzureCLI(t, true)
+	for _, name := range servicePrincipalEnvVars {
+		t.Setenv(name, "")
+	}
+
+	assert.False(t, skippedBy(t, requireAzureCredentials), "Test was skipped with an active Azure CLI login")
+}
+
+func TestRequireAzureCredentialsSkipsOffline(t *testing.T) {
+	setOfflineOnly(t, true)
+	t.Setenv("AZURE_AUTH_METHOD", "")
+	stubAzureCLI(t, true)
+
+	// Confirm an offline (-tags validate) run skips even with working credentials
+	assert.True(t, skippedBy(t, requireAzureCredentials), "Test was not skipped in an offline run")
+}
+
+// setOfflineOnly sets offlineOnly for the rest of the test, whichever tags the package was built with
+func setOfflineOnly(t *testing.T, value bool) {
+	saved := offlineOnly
+	offlineOnly = value
+	t.Cleanup(func() { offlineOnly = saved })
+}
+
+func TestRequireFullProfile(t *testing.T) {
+	t.Setenv("TEST_PROFILE", "")
+	assert.True(t, skippedBy(t, requireFullProfile), "Test was not skipped outside the full profile")
+
+	t.Setenv("TEST_PROFILE", "full")
+	assert.False(t, skippedBy(t, requireFullProfile), "Test was skipped in the full profile")
+}
+
+// clearAuthEnv unsets every variable the auth methods read for the duration of the test
+func clearAuthEnv(t *testing.T) {
+	for _, name := range append(servicePrincipalEnvVars, "AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET", "AZURE_TENANT_ID") {
+		t.Setenv(name, "")
+	}
+}
+
+func TestAuthEnvironmentServicePrincipal(t *testing.T) {
+	clearAuthEnv(t)
+	t.Setenv("ARM_CLIENT_ID", "client")
+	t.Setenv("ARM_TENANT_ID", "tenant")
+
+	_, err := authEnvironment("sp")
+	require.Error(t, err, "Accepted a service principal without a secret")
+	assert.Contains(t, err.Error(), "ARM_CLIENT_SECRET, ARM_SUBSCRIPTION_ID")
+
+	t.Setenv("ARM_CLIENT_SECRET", "secret")
+	t.Setenv("ARM_SUBSCRIPTION_ID", "sub")
+	env, err := authEnvironment("sp")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"AZURE_CLIENT_ID": "client", "AZURE_CLIENT_SECRET": "secret", "AZURE_TENANT_ID": "tenant"}, env)
+}
+
+func TestAuthEnvironmentCLI(t *testing.T) {
+	clearAuthEnv(t)
+	stubAzureCLI(t, false)
+
+	_, err := authEnvironment("cli")
+	require.Error(t, err, "Accepted CLI auth without a login")
+	assert.Contains(t, err.Error(), "az login")
+
+	stubAzureCLI(t, true)
+	env, err := authEnvironment("cli")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ARM_USE_CLI": "true"}, env)
+
+	// Confirm SDK credentials that would shadow the CLI are rejected
+	t.Setenv("AZURE_CLIENT_ID", "client")
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	_, err = authEnvironment("cli")
+	assert.Error(t, err, "Accepted CLI auth with AZURE_CLIENT_ID and AZURE_TENANT_ID set")
+}
+
+func TestAuthEnvironmentMSI(t *testing.T) {
+	clearAuthEnv(t)
+	t.Setenv("ARM_CLIENT_ID", "identity")
+
+	_, err := authEnvironment("msi")
+	require.Error(t, err, "Accepted MSI auth without a tenant and subscription")
+	assert.Contains(t, err.Error(), "ARM_TENANT_ID, ARM_SUBSCRIPTION_ID")
+
+	t.Setenv("ARM_TENANT_ID", "tenant")
+	t.Setenv("ARM_SUBSCRIPTION_ID", "sub")
+	env, err := authEnvironment("msi")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ARM_USE_MSI": "true", "AZURE_CLIENT_ID": "identity", "AZURE_TENANT_ID": "tenant"}, env)
+
+	// Confirm a client secret, which would win over the identity, is rejected
+	t.Setenv("ARM_CLIENT_SECRET", "secret")
+	_, err = authEnvironment("msi")
+	assert.Error(t, err, "Accepted MSI auth with a client secret set")
+}
+
+func TestAuthEnvironmentUnknownMethod(t *testing.T) {
+	_, err := authEnvironment("password")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected cli, sp or msi")
+}
+
+// usage builds a compute usage entry as the usage API reports it
+func usage(name string, used int32, limit int64) compute.Usage {
+	return compute.Usage{Name: &compute.UsageName{Value: to.StringPtr(name)}, CurrentValue: to.Int32Ptr(used), Limit: to.Int64Ptr(limit)}
+}
+
+// stubQuota makes the quota lookups report a 2 vCPU standardBSFamily size and usages
+func stubQuota(t *testing.T, usages ...compute.Usage) {
+	originalUsages, originalFamily := computeUsagesE, vmSizeFamilyE
+	computeUsagesE = func(string, string) ([]compute.Usage, error) { return usages, nil }
+	vmSizeFamilyE = func(string, string, string) (string, int, error) { return "standardBSFamily", 2, nil }
+	t.Cleanup(func() { computeUsagesE, vmSizeFamilyE = originalUsages, originalFamily })
+}
+
+func TestCheckVCPUQuota(t *testing.T) {
+	testCases := []struct {
+		name    string
+		usages  []compute.Usage
+		wantErr string
+	}{
+		{"Headroom", []compute.Usage{usage("standardBSFamily", 2, 10), usage("cores", 4, 10)}, ""},
+		{"ExactFit", []compute.Usage{usage("standardBSFamily", 8, 10), usage("cores", 8, 10)}, ""},
+		{"FamilyExhausted", []compute.Usage{usage("standardBSFamily", 9, 10), usage("cores", 4, 10)}, "vCPU quota standardBSFamily is exhausted: need 2 vCPUs, have 1 available"},
+		{"RegionExhausted", []compute.Usage{usage("standardBSFamily", 0, 10), usage("cores", 10, 10)}, "vCPU quota cores is exhausted: need 2 vCPUs, have 0 available"},
+		{"FamilyMissing", []compute.Usage{usage("cores", 0, 10)}, "no standardBSFamily vCPU quota found"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			stubQuota(t, testCase.usages...)
+
+			err := checkVCPUQuotaE("sub", "West US 3", "Standard_B2s")
+			if testCase.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testCase.wantErr)
+			assert.Contains(t, err.Error(), "Standard_B2s in westus3", "Error does not name the size and region")
+		})
+	}
+}
+
+func TestCheckNameCollision(t *testing.T) {
+	t.Run("Free", func(t *testing.T) {
+		fake := useFakeAzure(t, &fakeAzureClient{})
+		assert.NoError(t, checkNameCollisionE("sub", "lian0138-A05-RG"))
+		assert.Equal(t, []string{"sub/lian0138-A05-RG"}, fake.scopes, "Preflight looked for the wrong resource group")
+	})
+
+	t.Run("Taken", func(t *testing.T) {
+		useFakeAzure(t, &fakeAzureClient{resourceGroupExists: true})
+
+		// Confirm the error names the colliding resource group and how to avoid it
+		err := checkNameCollisionE("sub", "lian0138-A05-RG")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "resource group lian0138-A05-RG already exists")
+		assert.Contains(t, err.Error(), "resource_group_suffix")
+	})
+
+	t.Run("LookupFails", func(t *testing.T) {
+		useFakeAzure(t, &fakeAzureClient{err: errors.New("authorization failed")})
+		assert.ErrorContains(t, checkNameCollisionE("sub", "lian0138-A05-RG"), "authorization failed")
+	})
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// moduleResourceGroupName returns the name the module gives its resource group for vars, as main.tf derives it
+// from labelPrefix and resource_group_suffix
+func moduleResourceGroupName(vars map[string]interface{}) string {
+	name := fmt.Sprintf("%v-A05-RG", vars["labelPrefix"])
+	if suffix, _ := vars["resource_group_suffix"].(string); suffix != "" {
+		name += "-" + suffix
+	}
+	return name
+}
+
+// createResourceGroupE creates an empty resource group in location
+func createResourceGroupE(resourceGroupName, location, subscriptionID string) error {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return err
+	}
+	client := resources.NewGroupsClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	_, err = client.CreateOrUpdate(context.Background(), resourceGroupName, resources.Group{Location: to.StringPtr(location)})
+	return wrapAzureErr("create resource group "+resourceGroupName, subscriptionID, err)
+}
+
+// deleteResourceGroupE deletes a resource group and everything in it, waiting for the deletion to finish
+func deleteResourceGroupE(resourceGroupName, subscriptionID string) error {
+	subscriptionID, authorizer, err := clientConfigE(subscriptionID)
+	if err != nil {
+		return err
+	}
+	client := resources.NewGroupsClient(subscriptionID)
+	client.Authorizer = authorizer
+
+	op := "delete resource group " + resourceGroupName
+	future, err := client.Delete(context.Background(), resourceGroupName)
+	if err != nil {
+		return wrapAzureErr(op, subscriptionID, err)
+	}
+	return wrapAzureErr(op, subscriptionID, future.WaitForCompletionRef(context.Background(), client.Client))
+}
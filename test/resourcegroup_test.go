@@ -0,0 +1,13 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleResourceGroupName(t *testing.T) {
+	assert.Equal(t, "lian0138-A05-RG", moduleResourceGroupName(map[string]interface{}{"labelPrefix": "lian0138"}))
+	assert.Equal(t, "lian0138-A05-RG", moduleResourceGroupName(map[string]interface{}{"labelPrefix": "lian0138", "resource_group_suffix": ""}))
+	assert.Equal(t, "lian0138-A05-RG-ci-42", moduleResourceGroupName(map[string]interface{}{"labelPrefix": "lian0138", "resource_group_suffix": "ci-42"}))
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceID is a parsed Azure Resource Manager resource ID. For child resources such as subnets, Type and
+// Name hold the full path below the provider, e.g. "virtualNetworks/subnets" and "vnet/subnet".
+type ResourceID struct {
+	SubscriptionID string
+	ResourceGroup  string
+	Provider       string
+	Type           string
+	Name           string
+}
+
+// parseAzureResourceID parses an ID of the form
+// /subscriptions/{sub}/resourceGroups/{rg}/providers/{provider}/{type}/{name}[/{childType}/{childName}...].
+// Azure is inconsistent about the casing of the fixed segments (resourceGroups vs resourcegroups), so
+// those are matched case-insensitively; empty segments and missing or unpaired segments are errors.
+func parseAzureResourceID(id string) (ResourceID, error) {
+	if !strings.HasPrefix(id, "/") {
+		return ResourceID{}, fmt.Errorf("resource ID %q does not start with /", id)
+	}
+	segments := strings.Split(strings.TrimPrefix(id, "/"), "/")
+	for i, segment := range segments {
+		if segment == "" {
+			return ResourceID{}, fmt.Errorf("resource ID %q has an empty segment at position %d", id, i+1)
+		}
+	}
+
+	fixed := []string{"subscriptions", "", "resourceGroups", "", "providers", ""}
+	if len(segments) < len(fixed)+2 {
+		return ResourceID{}, fmt.Errorf("resource ID %q is too short, expected /subscriptions/{sub}/resourceGroups/{rg}/providers/{provider}/{type}/{name}", id)
+	}
+	for i, key := range fixed {
+		if key != "" && !strings.EqualFold(segments[i], key) {
+			return ResourceID{}, fmt.Errorf("resource ID %q has %q where %q was expected", id, segments[i], key)
+		}
+	}
+
+	typesAndNames := segments[len(fixed):]
+	if len(typesAndNames)%2 != 0 {
+		return ResourceID{}, fmt.Errorf("resource ID %q has a resource type without a name", id)
+	}
+	types, names := []string{}, []string{}
+	for i := 0; i < len(typesAndNames); i += 2 {
+		types = append(types, typesAndNames[i])
+		names = append(names, typesAndNames[i+1])
+	}
+
+	return ResourceID{
+		SubscriptionID: segments[1],
+		ResourceGroup:  segments[3],
+		Provider:       segments[5],
+		Type:           strings.Join(types, "/"),
+		Name:           strings.Join(names, "/"),
+	}, nil
+}
+
+// String formats the ID in Azure's canonical form
+func (id ResourceID) String() string {
+	types, names := strings.Split(id.Type, "/"), strings.Split(id.Name, "/")
+	var path strings.Builder
+	for i := range types {
+		path.WriteString("/" + types[i])
+		if i < len(names) {
+			path.WriteString("/" + names[i])
+		}
+	}
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s%s", id.SubscriptionID, id.ResourceGroup, id.Provider, path.String())
+}
+
+// Equal reports whether two IDs name the same resource. Azure resource IDs are case-insensitive.
+func (id ResourceID) Equal(other ResourceID) bool {
+	return strings.EqualFold(id.String(), other.String())
+}
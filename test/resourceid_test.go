@@ -0,0 +1,74 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAzureResourceID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		id       string
+		expected ResourceID
+	}{
+		{
+			"TopLevel",
+			"/subscriptions/sub-1/resourceGroups/lian0138-A05-RG/providers/Microsoft.Network/networkInterfaces/lian0138A05Nic",
+			ResourceID{"sub-1", "lian0138-A05-RG", "Microsoft.Network", "networkInterfaces", "lian0138A05Nic"},
+		},
+		{
+			"Child",
+			"/subscriptions/sub-1/resourceGroups/lian0138-A05-RG/providers/Microsoft.Network/virtualNetworks/lian0138A05Vnet/subnets/lian0138A05Subnet",
+			ResourceID{"sub-1", "lian0138-A05-RG", "Microsoft.Network", "virtualNetworks/subnets", "lian0138A05Vnet/lian0138A05Subnet"},
+		},
+		{
+			"LowercaseSegments",
+			"/subscriptions/sub-1/resourcegroups/lian0138-A05-RG/providers/Microsoft.Compute/virtualMachines/lian0138A05VM",
+			ResourceID{"sub-1", "lian0138-A05-RG", "Microsoft.Compute", "virtualMachines", "lian0138A05VM"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			id, err := parseAzureResourceID(testCase.id)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, id)
+		})
+	}
+}
+
+func TestParseAzureResourceIDRejectsMalformed(t *testing.T) {
+	testCases := map[string]string{
+		"Empty":             "",
+		"NoLeadingSlash":    "subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic",
+		"MissingName":       "/subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces",
+		"MissingProvider":   "/subscriptions/sub-1/resourceGroups/rg/networkInterfaces/nic",
+		"ResourceGroupOnly": "/subscriptions/sub-1/resourceGroups/rg",
+		"DoubleSlash":       "/subscriptions/sub-1/resourceGroups//providers/Microsoft.Network/networkInterfaces/nic",
+		"TrailingSlash":     "/subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic/",
+		"UnpairedChild":     "/subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets",
+		"WrongKey":          "/subscriptions/sub-1/groups/rg/providers/Microsoft.Network/networkInterfaces/nic",
+	}
+
+	for name, id := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseAzureResourceID(id)
+			assert.Error(t, err, "Accepted malformed ID %q", id)
+		})
+	}
+}
+
+func TestResourceIDRoundTripAndEqual(t *testing.T) {
+	original := "/subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/web"
+	id, err := parseAzureResourceID(original)
+	require.NoError(t, err)
+	assert.Equal(t, original, id.String())
+
+	// Confirm IDs differing only in casing are the same resource, and different names are not
+	other, err := parseAzureResourceID("/subscriptions/SUB-1/resourcegroups/RG/providers/microsoft.network/virtualnetworks/vnet/subnets/web")
+	require.NoError(t, err)
+	assert.True(t, id.Equal(other))
+	assert.False(t, id.Equal(ResourceID{"sub-1", "rg", "Microsoft.Network", "virtualNetworks/subnets", "vnet/app"}))
+}
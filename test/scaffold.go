@@ -0,0 +1,31 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runModuleTest deploys a standalone copy of the module with vars (see isolatedOptions), runs assertions on
+// its outputs and destroys it, so a standalone test needs only its vars and assertions
+func runModuleTest(t *testing.T, vars map[string]interface{}, assertions func(t *testing.T, out Outputs)) {
+	t.Helper()
+	deployAndAssert(t, isolatedOptions(t, vars), func(out Outputs) { assertions(t, out) })
+}
+
+// deployAndAssert applies options with the pre-apply hooks, passes its outputs to assertions, then destroys
+// it and runs the post-destroy hooks. The destroy is deferred, so it runs even when the apply, the output
+// read or an assertion stops the test.
+func deployAndAssert(t testing.TB, options *terraform.Options, assertions func(out Outputs)) {
+	t.Helper()
+	defer func() {
+		_, err := terraformDestroyE(t, options)
+		assert.NoError(t, err, "Failed to destroy the deployment")
+		assert.NoError(t, runPostDestroyHooksE(t, options), "Post-destroy hooks failed")
+	}()
+
+	require.NoError(t, applyWithHooksE(t, options), "Failed to apply the deployment")
+	assertions(loadOutputs(t, options))
+}
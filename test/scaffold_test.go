@@ -0,0 +1,102 @@
+package test
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// stoppableT records failures like testing.T, including stopping on FailNow, without failing the real test
+type stoppableT struct {
+	*testing.T
+	failed bool
+}
+
+func (s *stoppableT) Errorf(string, ...interface{}) { s.failed = true }
+func (s *stoppableT) Fail()                         { s.failed = true }
+func (s *stoppableT) Failed() bool                  { return s.failed }
+
+func (s *stoppableT) FailNow() {
+	s.failed = true
+	runtime.Goexit()
+}
+
+// runStoppable runs f against a stoppableT on its own goroutine, as FailNow must, and returns it once f ends
+func runStoppable(t *testing.T, f func(testing.TB)) *stoppableT {
+	stoppable := &stoppableT{T: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(stoppable)
+	}()
+	<-done
+	return stoppable
+}
+
+// stubLifecycle replaces apply, output and destroy for the duration of the test, appending each call to
+// events; the apply fails with applyErr when it is set
+func stubLifecycle(t *testing.T, events *[]string, applyErr error) {
+	stubHooks(t)
+	stubOutputs(t, sampleOutputs())
+	originalApply, originalDestroy := terraformInitAndApplyE, terraformDestroyE
+	terraformInitAndApplyE = func(terratesting.TestingT, *terraform.Options) (string, error) {
+		*events = append(*events, "apply")
+		return "", applyErr
+	}
+	terraformDestroyE = func(terratesting.TestingT, *terraform.Options) (string, error) {
+		*events = append(*events, "destroy")
+		return "", nil
+	}
+	t.Cleanup(func() { terraformInitAndApplyE, terraformDestroyE = originalApply, originalDestroy })
+}
+
+func TestDeployAndAssert(t *testing.T) {
+	options := &terraform.Options{TerraformDir: "/tmp/module"}
+
+	t.Run("Passes", func(t *testing.T) {
+		events := []string{}
+		stubLifecycle(t, &events, nil)
+
+		// Confirm the assertions get the typed outputs between apply and destroy
+		result := runStoppable(t, func(tb testing.TB) {
+			deployAndAssert(tb, options, func(out Outputs) {
+				events = append(events, "assert "+out.VMName)
+			})
+		})
+		assert.False(t, result.failed)
+		assert.Equal(t, []string{"apply", "assert lian0138A05VM", "destroy"}, events)
+	})
+
+	t.Run("ApplyFails", func(t *testing.T) {
+		events := []string{}
+		stubLifecycle(t, &events, errors.New("Error: creating Linux Virtual Machine: SkuNotAvailable"))
+
+		// Confirm a failed apply skips the assertions but still destroys the partial deployment
+		result := runStoppable(t, func(tb testing.TB) {
+			deployAndAssert(tb, options, func(Outputs) {
+				events = append(events, "assert")
+			})
+		})
+		assert.True(t, result.failed, "A failed apply did not fail the test")
+		assert.Equal(t, []string{"apply", "destroy"}, events)
+	})
+
+	t.Run("AssertionStops", func(t *testing.T) {
+		events := []string{}
+		stubLifecycle(t, &events, nil)
+
+		// Confirm an assertion that stops the test still leaves the destroy to run
+		result := runStoppable(t, func(tb testing.TB) {
+			deployAndAssert(tb, options, func(Outputs) {
+				events = append(events, "assert")
+				tb.FailNow()
+			})
+		})
+		assert.True(t, result.failed)
+		assert.Equal(t, []string{"apply", "assert", "destroy"}, events)
+	})
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// span is a named, timed phase of the test run
+type span struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+}
+
+// spanExporter ships finished spans beyond the log file. It stays nil, and costs nothing,
+// unless an exporter built in with a tag (see spans_otlp.go) is configured.
+var spanExporter func(spans []span) error
+
+var (
+	spansMu    sync.Mutex
+	spans      []span
+	timedTests = map[string]bool{}
+)
+
+// timeit runs fn and records how long it took under name, even if fn fails the test
+func timeit(name string, fn func()) {
+	defer startSpan(name)()
+	fn()
+}
+
+// startSpan starts timing name and returns the func that ends the span
+func startSpan(name string) func() {
+	start := time.Now()
+	return func() {
+		recordSpan(span{Name: name, Start: start, Duration: time.Since(start)})
+	}
+}
+
+// timeTest records a span covering the rest of the test, once per test
+func timeTest(t *testing.T) {
+	spansMu.Lock()
+	defer spansMu.Unlock()
+	if timedTests[t.Name()] {
+		return
+	}
+	timedTests[t.Name()] = true
+	t.Cleanup(startSpan("test " + t.Name()))
+}
+
+// recordSpan keeps a finished span for export and writes it to the log
+func recordSpan(s span) {
+	spansMu.Lock()
+	spans = append(spans, s)
+	spansMu.Unlock()
+	fmt.Printf("[timing] %s took %s\n", s.Name, s.Duration.Round(time.Millisecond))
+}
+
+// exportSpans hands every recorded span to the configured exporter, if any
+func exportSpans() error {
+	if spanExporter == nil {
+		return nil
+	}
+	spansMu.Lock()
+	finished := append([]span(nil), spans...)
+	spansMu.Unlock()
+	return spanExporter(finished)
+}
@@ -0,0 +1,81 @@
+//go:build otel
+
+// Build with `-tags otel` and set OTEL_EXPORTER_OTLP_ENDPOINT (e.g. http://localhost:4318)
+// to send the run's timing spans to an OpenTelemetry collector over OTLP/HTTP JSON.
+
+package test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if endpoint == "" {
+		return
+	}
+	spanExporter = func(spans []span) error {
+		return postOTLPSpans(strings.TrimSuffix(endpoint, "/")+"/v1/traces", spans)
+	}
+}
+
+// postOTLPSpans sends the spans as a single trace to an OTLP/HTTP traces endpoint
+func postOTLPSpans(url string, spans []span) error {
+	traceID := randomHex(16)
+	otlpSpans := []map[string]interface{}{}
+	for _, s := range spans {
+		otlpSpans = append(otlpSpans, map[string]interface{}{
+			"traceId":           traceID,
+			"spanId":            randomHex(8),
+			"name":              s.Name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.Start.Add(s.Duration).UnixNano(), 10),
+		})
+	}
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []interface{}{map[string]interface{}{
+					"key":   "service.name",
+					"value": map[string]interface{}{"stringValue": "cst8918-terratest"},
+				}},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "terratest"},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP export to %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// randomHex returns n random bytes hex-encoded, as OTLP JSON expects for trace and span IDs
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,32 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeitRecordsSpan(t *testing.T) {
+	spansMu.Lock()
+	before := len(spans)
+	spansMu.Unlock()
+
+	timeit("unit-phase", func() { time.Sleep(10 * time.Millisecond) })
+
+	spansMu.Lock()
+	recorded := append([]span(nil), spans[before:]...)
+	spansMu.Unlock()
+	require.Len(t, recorded, 1)
+	assert.Equal(t, "unit-phase", recorded[0].Name)
+	assert.GreaterOrEqual(t, recorded[0].Duration, 10*time.Millisecond, "Span duration is shorter than the wrapped function")
+}
+
+func TestExportSpansWithoutExporter(t *testing.T) {
+	original := spanExporter
+	spanExporter = nil
+	t.Cleanup(func() { spanExporter = original })
+
+	assert.NoError(t, exportSpans(), "Exporting without a configured exporter should be a no-op")
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/ssh"
+	"github.com/stretchr/testify/require"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// sshHost returns an SSH host for the deployment's VM. It authenticates with the private key matching
+// the public key the module installs (~/.ssh/id_rsa), which SSH_PRIVATE_KEY_PATH overrides.
+func sshHost(t *testing.T, out Outputs) ssh.Host {
+	keyPath := os.Getenv("SSH_PRIVATE_KEY_PATH")
+	if keyPath == "" {
+		home, err := os.UserHomeDir()
+		require.NoError(t, err, "Failed to find the home directory")
+		keyPath = filepath.Join(home, ".ssh", "id_rsa")
+	}
+	privateKey, err := os.ReadFile(keyPath)
+	require.NoError(t, err, "Failed to read SSH private key %s", keyPath)
+
+	return ssh.Host{
+		Hostname:    out.PublicIP,
+		SshUserName: out.AdminUsername,
+		SshKeyPair:  &ssh.KeyPair{PrivateKey: string(privateKey)},
+	}
+}
+
+// runSSHCommand runs command on the host, retrying for up to SSH_TIMEOUT while the VM is still booting
+func runSSHCommand(t *testing.T, host ssh.Host, command string) string {
+	return ssh.CheckSshCommandWithRetry(t, host, command, testTimeouts.retries(testTimeouts.SSH), testTimeouts.RetryInterval)
+}
+
+// bootIDCommand prints an ID the kernel generates afresh on every boot
+const bootIDCommand = "cat /proc/sys/kernel/random/boot_id"
+
+// rebootAndWait reboots the VM and waits up to SSH_TIMEOUT for it to come back with a new boot ID.
+// SSH can keep answering for a moment after the reboot is issued and then drops, so failed connections
+// and the old boot ID are both retried rather than treated as failures or as recovery.
+func rebootAndWait(t *testing.T, host ssh.Host) {
+	before := strings.TrimSpace(runSSHCommand(t, host, bootIDCommand))
+
+	// Delay the reboot so the SSH session that issues it can exit cleanly
+	runSSHCommand(t, host, "sudo systemd-run --on-active=2 systemctl reboot")
+
+	ok := assertEventually(t, testTimeouts.SSH, testTimeouts.RetryInterval, func() (bool, string) {
+		after, err := ssh.CheckSshCommandE(t, host, bootIDCommand)
+		if err != nil {
+			return false, err.Error()
+		}
+		return strings.TrimSpace(after) != before, "VM has not rebooted yet"
+	}, "VM did not come back from reboot")
+	if !ok {
+		t.FailNow()
+	}
+}
+
+// errHostKeyCaptured aborts the handshake in hostKeyFingerprintE once the server has presented its key
+var errHostKeyCaptured = errors.New("host key captured")
+
+// hostKeyFingerprintE returns the SHA256 fingerprint of the host key the SSH server at addr presents, as
+// ssh-keygen -l prints it. The handshake stops at key exchange, so no credentials are needed.
+func hostKeyFingerprintE(addr string, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+
+	var hostKey cryptossh.PublicKey
+	config := &cryptossh.ClientConfig{
+		HostKeyCallback: func(_ string, _ net.Addr, key cryptossh.PublicKey) error {
+			hostKey = key
+			return errHostKeyCaptured
+		},
+	}
+	_, _, _, err = cryptossh.NewClientConn(conn, addr, config)
+	if hostKey == nil {
+		return "", fmt.Errorf("no host key presented by %s: %w", addr, err)
+	}
+	return cryptossh.FingerprintSHA256(hostKey), nil
+}
@@ -0,0 +1,41 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+func TestHostKeyFingerprint(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := cryptossh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// Serve a single SSH handshake with the generated host key
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		config := &cryptossh.ServerConfig{NoClientAuth: true}
+		config.AddHostKey(signer)
+		_, _, _, _ = cryptossh.NewServerConn(conn, config)
+	}()
+
+	// Confirm the fingerprint is that of the server's key, without authenticating
+	fingerprint, err := hostKeyFingerprintE(listener.Addr().String(), 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, cryptossh.FingerprintSHA256(signer.PublicKey()), fingerprint)
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+)
+
+// createdAtTag is the tag the module stamps with its first apply time, for the cleanup janitor to age resources by
+const createdAtTag = "created_at"
+
+// createdAtE parses the created_at tag from a resource's tags
+func createdAtE(tags map[string]*string) (time.Time, error) {
+	value, ok := tags[createdAtTag]
+	if !ok || value == nil {
+		return time.Time{}, fmt.Errorf("no %s tag in %v", createdAtTag, to.StringMap(tags))
+	}
+	createdAt, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s tag %q is not RFC3339: %w", createdAtTag, *value, err)
+	}
+	return createdAt, nil
+}
+
+// assertTagsSubset checks that every key in expected is among an Azure resource's tags with the same value,
+// ignoring extra tags. A nil map counts as no tags and a nil value as a tag with no value, so SDK tags need no
+// guarding. Every missing or different tag is reported in one failure, with msgAndArgs naming the resource;
+// it returns whether all matched.
+func assertTagsSubset(t assert.TestingT, actual map[string]*string, expected map[string]string, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	problems := []string{}
+	for key, want := range expected {
+		value, ok := actual[key]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s is missing", key))
+		case value == nil:
+			problems = append(problems, fmt.Sprintf("%s has no value, expected %q", key, want))
+		case *value != want:
+			problems = append(problems, fmt.Sprintf("%s is %q, expected %q", key, *value, want))
+		}
+	}
+	if len(problems) == 0 {
+		return true
+	}
+	sort.Strings(problems)
+	return assert.Fail(t, fmt.Sprintf("Tags do not match: %s; actual tags are %v", strings.Join(problems, ", "), to.StringMap(actual)), msgAndArgs...)
+}
+
+// pendingTagChanges lists the tag changes a plan would make to existing resources, one line per tag as
+// "address: key before -> after", sorted. Tags only known after apply show as (known after apply).
+func pendingTagChanges(plan *tfjson.Plan) []string {
+	changes := []string{}
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode != tfjson.ManagedResourceMode || rc.Change == nil || !rc.Change.Actions.Update() {
+			continue
+		}
+		if afterUnknown, ok := rc.Change.AfterUnknown.(map[string]interface{}); ok && afterUnknown["tags"] == true {
+			changes = append(changes, fmt.Sprintf("%s: tags (known after apply)", rc.Address))
+			continue
+		}
+		before := planTags(rc.Change.Before)
+		after := planTags(rc.Change.After)
+		unknown := planTags(rc.Change.AfterUnknown)
+
+		keys := map[string]bool{}
+		for key := range before {
+			keys[key] = true
+		}
+		for key := range after {
+			keys[key] = true
+		}
+		for key := range unknown {
+			keys[key] = true
+		}
+		for key := range keys {
+			switch {
+			case unknown[key] == true:
+				changes = append(changes, fmt.Sprintf("%s: %s %s -> (known after apply)", rc.Address, key, tagValue(before, key)))
+			case tagValue(before, key) != tagValue(after, key):
+				changes = append(changes, fmt.Sprintf("%s: %s %s -> %s", rc.Address, key, tagValue(before, key), tagValue(after, key)))
+			}
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// planTags returns the tags attribute of a resource's values in a plan, or nil if it has none
+func planTags(values interface{}) map[string]interface{} {
+	attributes, _ := values.(map[string]interface{})
+	tags, _ := attributes["tags"].(map[string]interface{})
+	return tags
+}
+
+// tagValue formats a tag's value from planTags for pendingTagChanges, with (none) for an absent tag
+func tagValue(tags map[string]interface{}, key string) string {
+	value, ok := tags[key]
+	if !ok {
+		return "(none)"
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(value))
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatedAt(t *testing.T) {
+	createdAt, err := createdAtE(map[string]*string{"created_at": to.StringPtr("2024-03-01T12:00:00Z")})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), createdAt)
+
+	// Confirm a missing or malformed tag reports what was actually there
+	_, err = createdAtE(map[string]*string{"owner": to.StringPtr("lian0138")})
+	assert.ErrorContains(t, err, "no created_at tag in map[owner:lian0138]")
+	_, err = createdAtE(map[string]*string{"created_at": to.StringPtr("yesterday")})
+	assert.ErrorContains(t, err, `created_at tag "yesterday" is not RFC3339`)
+}
+
+func TestAssertTagsSubset(t *testing.T) {
+	actual := map[string]*string{
+		"created_at": to.StringPtr("2024-03-01T12:00:00Z"),
+		"owner":      to.StringPtr("lian0138"),
+		"empty":      nil,
+	}
+
+	testCases := []struct {
+		name     string
+		actual   map[string]*string
+		expected map[string]string
+		failure  []string
+	}{
+		{"Subset", actual, map[string]string{"owner": "lian0138"}, nil},
+		{"All", actual, map[string]string{"owner": "lian0138", "created_at": "2024-03-01T12:00:00Z"}, nil},
+		{"NothingExpected", nil, nil, nil},
+		{"MissingKey", actual, map[string]string{"course": "cst8918"}, []string{"course is missing"}},
+		{"WrongValue", actual, map[string]string{"owner": "someone-else"}, []string{`owner is "lian0138", expected "someone-else"`}},
+		{"NilValue", actual, map[string]string{"empty": "x"}, []string{`empty has no value, expected "x"`}},
+		{"NilMap", nil, map[string]string{"owner": "lian0138"}, []string{"owner is missing", "actual tags are map[]"}},
+		{"SeveralProblems", actual, map[string]string{"owner": "x", "course": "cst8918"}, []string{`course is missing, owner is "lian0138", expected "x"`}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			recorder := &failureRecorder{T: t}
+
+			matched := assertTagsSubset(recorder, testCase.actual, testCase.expected, "VM %s", "lian0138A05VM")
+			if testCase.failure == nil {
+				assert.True(t, matched)
+				assert.Empty(t, recorder.failures)
+				return
+			}
+			assert.False(t, matched)
+			require.Len(t, recorder.failures, 1, "Expected one failure for all problems")
+			assert.Contains(t, recorder.failures[0], "VM lian0138A05VM", "Failure does not name the resource")
+			for _, part := range testCase.failure {
+				assert.Contains(t, recorder.failures[0], part)
+			}
+		})
+	}
+}
+
+func TestPendingTagChanges(t *testing.T) {
+	change := func(address string, actions tfjson.Actions, before, after, afterUnknown interface{}) *tfjson.ResourceChange {
+		return &tfjson.ResourceChange{
+			Address: address,
+			Mode:    tfjson.ManagedResourceMode,
+			Change:  &tfjson.Change{Actions: actions, Before: before, After: after, AfterUnknown: afterUnknown},
+		}
+	}
+	tags := func(tags map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"tags": tags}
+	}
+	update := tfjson.Actions{tfjson.ActionUpdate}
+	settled := map[string]interface{}{"created_at": "2024-03-01T12:00:00Z"}
+
+	// Confirm unchanged, created and non-tag updates report nothing
+	assert.Empty(t, pendingTagChanges(&tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		change("azurerm_resource_group.rg", tfjson.Actions{tfjson.ActionNoop}, tags(settled), tags(settled), map[string]interface{}{}),
+		change("azurerm_public_ip.webserver[0]", tfjson.Actions{tfjson.ActionCreate}, nil, tags(nil), map[string]interface{}{"tags": true}),
+		change("azurerm_linux_virtual_machine.webserver[0]", update, tags(settled), tags(settled), map[string]interface{}{}),
+	}}))
+
+	// Confirm changed, added, removed and unknown tags are each reported
+	assert.Equal(t, []string{
+		`azurerm_linux_virtual_machine.webserver[0]: created_at "2024-03-01T12:00:00Z" -> (known after apply)`,
+		`azurerm_linux_virtual_machine.webserver[0]: owner "someone-else" -> (none)`,
+		`azurerm_resource_group.rg: created_at "2024-03-01T12:00:00Z" -> "2024-03-02T08:00:00Z"`,
+		`azurerm_resource_group.rg: team (none) -> "web"`,
+		`azurerm_windows_virtual_machine.webserver[0]: tags (known after apply)`,
+	}, pendingTagChanges(&tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		change("azurerm_resource_group.rg", update,
+			tags(settled),
+			tags(map[string]interface{}{"created_at": "2024-03-02T08:00:00Z", "team": "web"}),
+			map[string]interface{}{}),
+		change("azurerm_linux_virtual_machine.webserver[0]", update,
+			tags(map[string]interface{}{"created_at": "2024-03-01T12:00:00Z", "owner": "someone-else"}),
+			tags(map[string]interface{}{}),
+			map[string]interface{}{"tags": map[string]interface{}{"created_at": true}}),
+		change("azurerm_windows_virtual_machine.webserver[0]", update, tags(settled), map[string]interface{}{}, map[string]interface{}{"tags": true}),
+	}}))
+}
@@ -0,0 +1,174 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// terraformDestroyE runs `terraform destroy`; unit tests swap it for a stub
+var terraformDestroyE = terraform.DestroyE
+
+// deployedResources names the resources a deployment created, captured before destroy
+type deployedResources struct {
+	SubscriptionID    string
+	ResourceGroupName string
+	VMName            string
+	PublicIPName      string
+	NSGName           string
+	VNetName          string
+}
+
+// destroy tears down the deployment, taking the fast path when FAST_DESTROY is set.
+//
+// The safe default refreshes state before destroying so anything that drifted is
+// still found and deleted. Skipping the refresh can save minutes in large
+// subscriptions, but terraform then only deletes what the state file already
+// knows about, so drifted or half-created resources may be left behind.
+func destroy(t testing.TestingT, options *terraform.Options) (string, error) {
+	if envFlag("FAST_DESTROY") {
+		return destroyFast(t, options)
+	}
+	return terraformDestroyE(t, options)
+}
+
+// cleanDestroyE destroys the deployment and fails if that takes more than one attempt. A first attempt
+// that fails, typically with an Azure dependency error such as a NIC still holding a public IP, means the
+// module's depends_on or lifecycle ordering is wrong. The error is logged and destroy is retried so the
+// resources are still cleaned up, but the returned error reports the first failure either way.
+func cleanDestroyE(t testing.TestingT, options *terraform.Options) error {
+	_, firstErr := destroy(t, options)
+	if firstErr == nil {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Destroy failed on the first attempt, retrying: %v\n", firstErr)
+
+	if _, err := destroy(t, options); err != nil {
+		return fmt.Errorf("destroy failed twice: first attempt: %v; second attempt: %w", firstErr, err)
+	}
+	return fmt.Errorf("destroy needed a second attempt; first attempt failed with: %w", firstErr)
+}
+
+// teardownAfterRun runs cleanup once the test run is over and returns the exit code to use.
+// With KEEP_ON_FAILURE set, a failed run's resources are kept so they can be inspected over SSH.
+func teardownAfterRun(exitCode int, resourceGroupName string, cleanup func() error) int {
+	if exitCode != 0 && envFlag("KEEP_ON_FAILURE") {
+		if resourceGroupName != "" {
+			fmt.Fprintf(os.Stderr, "KEEP_ON_FAILURE is set, so resource group %s was kept for debugging.\n"+
+				"Remember to run `terraform destroy` in the module directory when you are done.\n", resourceGroupName)
+		}
+		return exitCode
+	}
+
+	if err := cleanup(); err != nil {
+		fmt.Fprintf(os.Stderr, "Teardown failed: %v\n", err)
+		if exitCode == 0 {
+			return 1
+		}
+	}
+	return exitCode
+}
+
+// exitOnSignal waits in the background for a signal on signals, then runs cleanup and exits non-zero.
+// A cancelled run never reaches the teardown after m.Run, so without this its resources would be orphaned.
+// The returned func stops waiting once the run ends normally.
+func exitOnSignal(signals <-chan os.Signal, cleanup func(), exit func(int)) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(os.Stderr, "Received %v, cleaning up before exiting\n", sig)
+			cleanup()
+			exit(1)
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// destroyFast runs destroy with -refresh=false without modifying the caller's options
+func destroyFast(t testing.TestingT, options *terraform.Options) (string, error) {
+	fastOptions, err := options.Clone()
+	if err != nil {
+		return "", err
+	}
+	fastOptions.ExtraArgs.Destroy = append(fastOptions.ExtraArgs.Destroy, "-refresh=false")
+	return terraformDestroyE(t, fastOptions)
+}
+
+// stateAddressesE lists the resource addresses left in the deployment's state, which should be none after
+// a clean destroy
+func stateAddressesE(t testing.TestingT, options *terraform.Options) ([]string, error) {
+	output, err := terraform.RunTerraformCommandE(t, options, "state", "list")
+	if err != nil {
+		return nil, err
+	}
+	return parseStateList(output), nil
+}
+
+// parseStateList splits `terraform state list` output into addresses, dropping blank lines
+func parseStateList(output string) []string {
+	addresses := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if address := strings.TrimSpace(line); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// verifyTeardown checks that destroy removed the deployment and returns one error per leftover resource.
+// If the resource group is gone everything in it is too; otherwise every resource is checked so a single
+// report shows everything that failed to delete. Leftovers that keep costing or block a re-deploy, such as
+// a public IP or network resources held by a lingering association, carry the ID needed to delete them by hand.
+func verifyTeardown(resources deployedResources) []error {
+	rgExists, err := azureAPI.ResourceGroupExists(resources.ResourceGroupName, resources.SubscriptionID)
+	if err != nil {
+		return []error{fmt.Errorf("failed to check resource group %s: %w", resources.ResourceGroupName, err)}
+	}
+	if !rgExists {
+		return nil
+	}
+
+	leaks := []error{fmt.Errorf("resource group %s still exists", resources.ResourceGroupName)}
+
+	checks := []struct {
+		description  string
+		name         string
+		resourceType string
+		exists       func(name, resourceGroupName, subscriptionID string) (bool, error)
+	}{
+		{"VM", resources.VMName, "", azureAPI.VirtualMachineExists},
+		{"public IP", resources.PublicIPName, "publicIPAddresses", azureAPI.PublicAddressExists},
+		{"network security group", resources.NSGName, "networkSecurityGroups", azureAPI.NetworkSecurityGroupExists},
+		{"virtual network", resources.VNetName, "virtualNetworks", azureAPI.VirtualNetworkExists},
+	}
+	for _, check := range checks {
+		if check.name == "" {
+			continue
+		}
+		exists, err := check.exists(check.name, resources.ResourceGroupName, resources.SubscriptionID)
+		switch {
+		case err != nil:
+			leaks = append(leaks, fmt.Errorf("failed to check %s %s: %w", check.description, check.name, err))
+		case exists && check.resourceType == "":
+			leaks = append(leaks, fmt.Errorf("%s %s still exists", check.description, check.name))
+		case exists:
+			leaks = append(leaks, fmt.Errorf("%s %s was not deleted, delete it manually: %s", check.description, check.name,
+				ResourceID{resources.SubscriptionID, resources.ResourceGroupName, "Microsoft.Network", check.resourceType, check.name}))
+		}
+	}
+
+	return leaks
+}
@@ -0,0 +1,208 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubDestroy replaces the destroy runner for the duration of the test and records the options it receives
+func stubDestroy(t *testing.T) *[]*terraform.Options {
+	calls := []*terraform.Options{}
+	original := terraformDestroyE
+	terraformDestroyE = func(_ terratesting.TestingT, options *terraform.Options) (string, error) {
+		calls = append(calls, options)
+		return "", nil
+	}
+	t.Cleanup(func() { terraformDestroyE = original })
+	return &calls
+}
+
+func TestFastDestroyPassesNoRefresh(t *testing.T) {
+	calls := stubDestroy(t)
+	t.Setenv("FAST_DESTROY", "true")
+	options := &terraform.Options{TerraformDir: "../"}
+
+	_, err := destroy(t, options)
+	require.NoError(t, err)
+
+	// Confirm the runner saw -refresh=false and the caller's options were left alone
+	require.Len(t, *calls, 1)
+	assert.Contains(t, (*calls)[0].ExtraArgs.Destroy, "-refresh=false", "FAST_DESTROY did not pass -refresh=false")
+	assert.Empty(t, options.ExtraArgs.Destroy, "destroyFast modified the caller's options")
+}
+
+func TestDefaultDestroyRefreshes(t *testing.T) {
+	calls := stubDestroy(t)
+	t.Setenv("FAST_DESTROY", "")
+
+	_, err := destroy(t, &terraform.Options{TerraformDir: "../"})
+	require.NoError(t, err)
+
+	require.Len(t, *calls, 1)
+	assert.NotContains(t, (*calls)[0].ExtraArgs.Destroy, "-refresh=false", "Destroy skipped refresh without FAST_DESTROY")
+}
+
+// stubExistence replaces the teardown existence checks for the duration of the test. The resource group
+// exists when rgExists is set, and a resource exists when its name is in existing.
+func stubExistence(t *testing.T, rgExists bool, existing ...string) {
+	useFakeAzure(t, &fakeAzureClient{resourceGroupExists: rgExists, existing: existing})
+}
+
+// sampleResources names a full deployment for teardown verification
+func sampleResources() deployedResources {
+	return deployedResources{
+		SubscriptionID:    "sub",
+		ResourceGroupName: "rg",
+		VMName:            "vm",
+		PublicIPName:      "ip",
+		NSGName:           "nsg",
+		VNetName:          "vnet",
+	}
+}
+
+func TestVerifyTeardownReportsLingeringPublicIP(t *testing.T) {
+	stubExistence(t, true, "ip")
+
+	leaks := verifyTeardown(sampleResources())
+
+	// Confirm the report carries the resource ID needed for manual cleanup
+	require.Len(t, leaks, 2)
+	assert.Contains(t, leaks[1].Error(), "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/publicIPAddresses/ip")
+}
+
+func TestVerifyTeardownReportsAllNetworkLeaks(t *testing.T) {
+	stubExistence(t, true, "nsg", "vnet")
+
+	leaks := verifyTeardown(sampleResources())
+
+	// Confirm one report covers every leftover, each with its ID
+	require.Len(t, leaks, 3, "Expected the resource group, NSG and VNet to be reported")
+	assert.Contains(t, leaks[1].Error(), "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkSecurityGroups/nsg")
+	assert.Contains(t, leaks[2].Error(), "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet")
+}
+
+func TestVerifyTeardownCleanWhenGroupIsGone(t *testing.T) {
+	stubExistence(t, false, "vm", "ip", "nsg", "vnet")
+
+	assert.Empty(t, verifyTeardown(sampleResources()), "Nothing can leak once the resource group is deleted")
+}
+
+func TestVerifyTeardownReportsLookupFailure(t *testing.T) {
+	useFakeAzure(t, &fakeAzureClient{err: errors.New("authorization failed")})
+
+	// Confirm a failed lookup is reported rather than treated as a clean teardown
+	leaks := verifyTeardown(sampleResources())
+	require.Len(t, leaks, 1)
+	assert.Contains(t, leaks[0].Error(), "failed to check resource group rg: authorization failed")
+}
+
+func TestKeepOnFailureSkipsDestroy(t *testing.T) {
+	t.Setenv("KEEP_ON_FAILURE", "true")
+	cleaned := false
+
+	exitCode := teardownAfterRun(1, "lian0138-A05-RG", func() error {
+		cleaned = true
+		return nil
+	})
+
+	assert.False(t, cleaned, "Resources were destroyed although KEEP_ON_FAILURE is set and the run failed")
+	assert.Equal(t, 1, exitCode)
+}
+
+func TestKeepOnFailureDestroysPassingRun(t *testing.T) {
+	t.Setenv("KEEP_ON_FAILURE", "true")
+	cleaned := false
+
+	exitCode := teardownAfterRun(0, "lian0138-A05-RG", func() error {
+		cleaned = true
+		return nil
+	})
+
+	assert.True(t, cleaned, "A passing run should always be cleaned up")
+	assert.Equal(t, 0, exitCode)
+}
+
+func TestTeardownErrorFailsRun(t *testing.T) {
+	t.Setenv("KEEP_ON_FAILURE", "")
+
+	exitCode := teardownAfterRun(0, "lian0138-A05-RG", func() error {
+		return errors.New("public IP still exists")
+	})
+
+	assert.Equal(t, 1, exitCode, "A failed teardown should fail the run")
+}
+
+// failingDestroy makes the destroy runner fail the first failures calls, recording how many calls it saw
+func failingDestroy(t *testing.T, failures int) *int {
+	calls := 0
+	original := terraformDestroyE
+	terraformDestroyE = func(terratesting.TestingT, *terraform.Options) (string, error) {
+		calls++
+		if calls <= failures {
+			return "", errors.New("InUseSubnetCannotBeDeleted")
+		}
+		return "", nil
+	}
+	t.Cleanup(func() { terraformDestroyE = original })
+	return &calls
+}
+
+func TestCleanDestroyFirstAttempt(t *testing.T) {
+	calls := failingDestroy(t, 0)
+
+	require.NoError(t, cleanDestroyE(t, &terraform.Options{}))
+	assert.Equal(t, 1, *calls, "A clean destroy should take one attempt")
+}
+
+func TestCleanDestroyReportsSecondAttempt(t *testing.T) {
+	calls := failingDestroy(t, 1)
+
+	// Confirm a destroy that only succeeds when retried is still reported, with the dependency error
+	err := cleanDestroyE(t, &terraform.Options{})
+	require.Error(t, err, "A destroy that needed two attempts was accepted")
+	assert.Contains(t, err.Error(), "InUseSubnetCannotBeDeleted")
+	assert.Equal(t, 2, *calls, "Destroy was not retried after the first failure")
+}
+
+func TestExitOnSignalRunsCleanup(t *testing.T) {
+	signals := make(chan os.Signal, 1)
+	cleaned := false
+	exited := make(chan int, 1)
+	stop := exitOnSignal(signals, func() { cleaned = true }, func(code int) { exited <- code })
+	defer stop()
+
+	// Confirm a simulated Ctrl-C runs cleanup before exiting non-zero
+	signals <- os.Interrupt
+	select {
+	case code := <-exited:
+		assert.True(t, cleaned, "Cleanup did not run before exit")
+		assert.Equal(t, 1, code, "Interrupted run did not exit non-zero")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handler did not exit after the signal")
+	}
+}
+
+func TestExitOnSignalStops(t *testing.T) {
+	signals := make(chan os.Signal, 1)
+	exited := false
+	stop := exitOnSignal(signals, func() {}, func(int) { exited = true })
+
+	// Confirm a signal after the run ended normally is ignored
+	stop()
+	signals <- os.Interrupt
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, exited, "Handler exited after being stopped")
+}
+
+func TestParseStateList(t *testing.T) {
+	output := "azurerm_public_ip.webserver[0]\n\nazurerm_resource_group.rg\n"
+	assert.Equal(t, []string{"azurerm_public_ip.webserver[0]", "azurerm_resource_group.rg"}, parseStateList(output))
+	assert.Empty(t, parseStateList(""))
+}
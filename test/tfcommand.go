@@ -0,0 +1,33 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// terraformCommandE runs a terraform command and returns its output; unit tests swap it for a stub
+var terraformCommandE = terraform.RunTerraformCommandE
+
+// runTerraformCommand runs an arbitrary terraform command (e.g. "state", "list") in the options' folder
+// and returns its output, failing the test if it errors. It's the escape hatch for assertions the typed
+// terratest helpers don't cover.
+func runTerraformCommand(t *testing.T, options *terraform.Options, args ...string) string {
+	t.Helper()
+	output, err := terraformCommandE(t, options, args...)
+	require.NoError(t, err, "terraform %s failed", strings.Join(args, " "))
+	return output
+}
+
+// stateAddresses splits `terraform state list` output into resource addresses
+func stateAddresses(output string) []string {
+	addresses := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			addresses = append(addresses, line)
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,30 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubTerraformCommand makes terraform commands return output and records the arguments they were run with
+func stubTerraformCommand(t *testing.T, output string) *[][]string {
+	calls := [][]string{}
+	original := terraformCommandE
+	terraformCommandE = func(_ terratesting.TestingT, _ *terraform.Options, args ...string) (string, error) {
+		calls = append(calls, args)
+		return output, nil
+	}
+	t.Cleanup(func() { terraformCommandE = original })
+	return &calls
+}
+
+func TestRunTerraformCommandStateList(t *testing.T) {
+	calls := stubTerraformCommand(t, "azurerm_resource_group.rg\nazurerm_subnet.webserver[\"web\"]\n\n")
+
+	addresses := stateAddresses(runTerraformCommand(t, &terraform.Options{}, "state", "list"))
+
+	assert.Equal(t, [][]string{{"state", "list"}}, *calls)
+	assert.Equal(t, []string{"azurerm_resource_group.rg", `azurerm_subnet.webserver["web"]`}, addresses)
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// requiredTerraformVersion reads the required_version constraint from the module's terraform block
+func requiredTerraformVersion(dir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return "", err
+	}
+
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return "", diags
+		}
+		for _, block := range parsed.Body.(*hclsyntax.Body).Blocks {
+			if block.Type != "terraform" {
+				continue
+			}
+			attr, ok := block.Body.Attributes["required_version"]
+			if !ok {
+				continue
+			}
+			value, diags := attr.Expr.Value(&hcl.EvalContext{})
+			if diags.HasErrors() {
+				return "", diags
+			}
+			return value.AsString(), nil
+		}
+	}
+	return "", fmt.Errorf("no required_version found in %s", dir)
+}
+
+// checkTerraformVersion checks the version reported by `terraform version -json` against a
+// constraint such as ">= 1.5.0, < 2.0.0" and returns the reported version
+func checkTerraformVersion(versionJSON string, constraint string) (string, error) {
+	var reported struct {
+		TerraformVersion string `json:"terraform_version"`
+	}
+	if err := json.Unmarshal([]byte(versionJSON), &reported); err != nil {
+		return "", fmt.Errorf("failed to parse terraform version output: %w", err)
+	}
+
+	actual, err := version.NewVersion(reported.TerraformVersion)
+	if err != nil {
+		return "", fmt.Errorf("invalid terraform version %q: %w", reported.TerraformVersion, err)
+	}
+	constraints, err := version.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+	if !constraints.Check(actual) {
+		return actual.String(), fmt.Errorf("terraform %s does not satisfy the required %q", actual, constraint)
+	}
+	return actual.String(), nil
+}
+
+// providerRequirement is one entry of the module's required_providers block
+type providerRequirement struct {
+	Source  string
+	Version string
+}
+
+// requiredProviders reads the required_providers entries from the module's terraform block, keyed by local name
+func requiredProviders(dir string) (map[string]providerRequirement, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	providers := map[string]providerRequirement{}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for _, block := range parsed.Body.(*hclsyntax.Body).Blocks {
+			if block.Type != "terraform" {
+				continue
+			}
+			for _, nested := range block.Body.Blocks {
+				if nested.Type != "required_providers" {
+					continue
+				}
+				for name, attr := range nested.Body.Attributes {
+					value, diags := attr.Expr.Value(&hcl.EvalContext{})
+					if diags.HasErrors() {
+						return nil, diags
+					}
+					requirement := providerRequirement{}
+					if value.Type().IsObjectType() {
+						if value.Type().HasAttribute("source") {
+							requirement.Source = value.GetAttr("source").AsString()
+						}
+						if value.Type().HasAttribute("version") {
+							requirement.Version = value.GetAttr("version").AsString()
+						}
+					}
+					providers[name] = requirement
+				}
+			}
+		}
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no required_providers found in %s", dir)
+	}
+	return providers, nil
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerraformVersion(t *testing.T) {
+	requireTerraformCLI(t)
+	constraint, err := requiredTerraformVersion(moduleDir)
+	require.NoError(t, err, "Failed to read the module's required_version")
+
+	// Init a throwaway copy so the version report reflects the module's working directory
+	options := &terraform.Options{TerraformDir: copyModule(t)}
+	terraform.Init(t, options)
+	versionJSON := terraform.RunTerraformCommandAndGetStdout(t, options, "version", "-json")
+
+	actual, err := checkTerraformVersion(versionJSON, constraint)
+	assert.NoError(t, err, "Terraform CLI %s does not satisfy required_version %q", actual, constraint)
+}
+
+func TestRequiredTerraformVersion(t *testing.T) {
+	dir := t.TempDir()
+	providers := "terraform {\n  required_version = \">= 1.5.0, < 2.0.0\"\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "providers.tf"), []byte(providers), 0o644))
+
+	constraint, err := requiredTerraformVersion(dir)
+	require.NoError(t, err)
+	assert.Equal(t, ">= 1.5.0, < 2.0.0", constraint)
+}
+
+func TestRequiredProviders(t *testing.T) {
+	dir := t.TempDir()
+	providers := "terraform {\n  required_providers {\n    azurerm = {\n      source  = \"hashicorp/azurerm\"\n      version = \"~> 3.82\"\n    }\n  }\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "providers.tf"), []byte(providers), 0o644))
+
+	required, err := requiredProviders(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]providerRequirement{"azurerm": {Source: "hashicorp/azurerm", Version: "~> 3.82"}}, required)
+}
+
+func TestCheckTerraformVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		versionJSON string
+		wantErr     bool
+	}{
+		{"Satisfied", `{"terraform_version":"1.9.8","platform":"linux_amd64"}`, false},
+		{"TooOld", `{"terraform_version":"1.4.6","platform":"linux_amd64"}`, true},
+		{"NextMajor", `{"terraform_version":"2.0.0","platform":"linux_amd64"}`, true},
+		{"Malformed", `Terraform v1.9.8`, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := checkTerraformVersion(testCase.versionJSON, ">= 1.5.0, < 2.0.0")
+			if testCase.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
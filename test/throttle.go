@@ -0,0 +1,52 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+const (
+	// throttleAttempts caps how many times a throttled call is made before its 429 is returned
+	throttleAttempts = 5
+
+	// throttleBackoff is the wait used when a 429 carries no usable Retry-After hint
+	throttleBackoff = 10 * time.Second
+)
+
+// sleep waits between throttled attempts; unit tests replace it to record the delays
+var sleep = time.Sleep
+
+// throttleDelay reports whether err is an Azure Resource Manager 429 and how long its Retry-After header
+// asks the caller to wait, given as seconds or an HTTP date. Without a usable hint it returns throttleBackoff.
+func throttleDelay(err error, now time.Time) (time.Duration, bool) {
+	var detailed autorest.DetailedError
+	if !errors.As(err, &detailed) || detailed.Response == nil || detailed.Response.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	retryAfter := detailed.Response.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(retryAfter); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return throttleBackoff, true
+}
+
+// retryThrottledE calls fn until it succeeds, fails with something other than throttling, or has been
+// throttled throttleAttempts times, waiting out each 429's Retry-After in between
+func retryThrottledE[T any](fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		delay, throttled := throttleDelay(err, time.Now())
+		if !throttled || attempt == throttleAttempts {
+			return result, err
+		}
+		sleep(delay)
+	}
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// throttledErr builds the error an SDK call returns for a 429 with the given Retry-After header
+func throttledErr(retryAfter string) error {
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return autorest.NewErrorWithError(errors.New("too many requests"), "compute.VirtualMachinesClient", "Get", resp, "Failure responding to request")
+}
+
+// stubSleep records the delays retryThrottledE waits for instead of sleeping
+func stubSleep(t *testing.T) *[]time.Duration {
+	delays := []time.Duration{}
+	original := sleep
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	t.Cleanup(func() { sleep = original })
+	return &delays
+}
+
+func TestRetryThrottledHonorsRetryAfter(t *testing.T) {
+	delays := stubSleep(t)
+	calls := 0
+
+	result, err := retryThrottledE(func() (string, error) {
+		calls++
+		if calls == 1 {
+			return "", wrapAzureErr("get VM lian0138A05VM", "sub", throttledErr("7"))
+		}
+		return "vm", nil
+	})
+
+	// Confirm the call was retried once, after exactly the hinted delay
+	require.NoError(t, err)
+	assert.Equal(t, "vm", result)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []time.Duration{7 * time.Second}, *delays)
+}
+
+func TestRetryThrottledGivesUp(t *testing.T) {
+	delays := stubSleep(t)
+	calls := 0
+
+	_, err := retryThrottledE(func() (string, error) {
+		calls++
+		return "", throttledErr("")
+	})
+
+	require.Error(t, err)
+	assert.Equal(t, throttleAttempts, calls, "Throttled call was not retried up to the cap")
+	assert.Len(t, *delays, throttleAttempts-1)
+	assert.Equal(t, throttleBackoff, (*delays)[0], "A 429 without Retry-After did not fall back to the fixed backoff")
+}
+
+func TestRetryThrottledIgnoresOtherErrors(t *testing.T) {
+	delays := stubSleep(t)
+	calls := 0
+
+	_, err := retryThrottledE(func() (string, error) {
+		calls++
+		return "", errors.New("resource not found")
+	})
+
+	require.Error(t, err)
+	assert.Equal(t, 1, calls, "A non-throttling error was retried")
+	assert.Empty(t, *delays)
+}
+
+func TestThrottleDelay(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		err           error
+		wantDelay     time.Duration
+		wantThrottled bool
+	}{
+		{"Seconds", throttledErr("30"), 30 * time.Second, true},
+		{"HTTPDate", throttledErr(now.Add(45 * time.Second).Format(http.TimeFormat)), 45 * time.Second, true},
+		{"PastDate", throttledErr(now.Add(-time.Minute).Format(http.TimeFormat)), 0, true},
+		{"NoHint", throttledErr(""), throttleBackoff, true},
+		{"NotThrottled", errors.New("boom"), 0, false},
+		{"NoError", nil, 0, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			delay, throttled := throttleDelay(testCase.err, now)
+			assert.Equal(t, testCase.wantThrottled, throttled)
+			assert.Equal(t, testCase.wantDelay, delay)
+		})
+	}
+}
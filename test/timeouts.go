@@ -0,0 +1,34 @@
+package test
+
+import "time"
+
+// timeouts bounds how long the suite waits for things that become ready asynchronously. Each is a total
+// wait, polled every RetryInterval, so slow networks can raise them and fast CI can shrink them from the
+// environment without code edits.
+type timeouts struct {
+	HTTP          time.Duration // HTTP_TIMEOUT: the web server answering with the expected content
+	SSH           time.Duration // SSH_TIMEOUT: the VM accepting SSH and a command succeeding
+	NIC           time.Duration // NIC_TIMEOUT: the VM's network profile listing its NICs
+	RetryInterval time.Duration // RETRY_INTERVAL: the pause between attempts
+}
+
+// testTimeouts is the suite's timeout configuration, read once from the environment
+var testTimeouts = loadTimeouts()
+
+// loadTimeouts reads the timeouts from the environment, using the defaults for unset or invalid values
+func loadTimeouts() timeouts {
+	return timeouts{
+		HTTP:          envDuration("HTTP_TIMEOUT", 5*time.Minute),
+		SSH:           envDuration("SSH_TIMEOUT", 5*time.Minute),
+		NIC:           envDuration("NIC_TIMEOUT", 100*time.Second),
+		RetryInterval: envDuration("RETRY_INTERVAL", 10*time.Second),
+	}
+}
+
+// retries converts a total wait into the retry count terratest's retry helpers expect
+func (tt timeouts) retries(total time.Duration) int {
+	if tt.RetryInterval <= 0 {
+		return 1
+	}
+	return max(1, int(total/tt.RetryInterval))
+}
@@ -0,0 +1,32 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTimeoutsDefaults(t *testing.T) {
+	for _, name := range []string{"HTTP_TIMEOUT", "SSH_TIMEOUT", "NIC_TIMEOUT", "RETRY_INTERVAL"} {
+		t.Setenv(name, "")
+	}
+
+	tt := loadTimeouts()
+	assert.Equal(t, 5*time.Minute, tt.SSH)
+	assert.Equal(t, 10*time.Second, tt.RetryInterval)
+	assert.Equal(t, 30, tt.retries(tt.SSH))
+}
+
+func TestLoadTimeoutsEnvOverride(t *testing.T) {
+	t.Setenv("SSH_TIMEOUT", "90s")
+	t.Setenv("RETRY_INTERVAL", "3s")
+	t.Setenv("HTTP_TIMEOUT", "not-a-duration")
+
+	// Confirm set variables override the defaults and an invalid one falls back
+	tt := loadTimeouts()
+	assert.Equal(t, 90*time.Second, tt.SSH)
+	assert.Equal(t, 3*time.Second, tt.RetryInterval)
+	assert.Equal(t, 30, tt.retries(tt.SSH))
+	assert.Equal(t, 5*time.Minute, tt.HTTP)
+}
@@ -0,0 +1,280 @@
+//go:build validate
+
+// Build with `-tags validate` to add the credential-free checks below, which need only the terraform CLI:
+//
+//	go test -tags validate ./...
+//
+// None of them plan against or call Azure, and the tag skips every test that would (see offlineOnly), so
+// CI can run them on every push without a subscription.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	offlineOnly = true
+}
+
+// countIndex strips a resource instance key such as [0] from an address
+var countIndex = regexp.MustCompile(`\[[^\]]*\]$`)
+
+func TestValidateModule(t *testing.T) {
+	requireTerraformCLI(t)
+
+	// Init without a backend so no state or credentials are touched, then validate the configuration
+	options := &terraform.Options{TerraformDir: copyModule(t), NoColor: true}
+	terraform.RunTerraformCommand(t, options, "init", "-backend=false", "-input=false")
+	terraform.Validate(t, options)
+}
+
+func TestTerraformFmt(t *testing.T) {
+	requireTerraformCLI(t)
+	options := &terraform.Options{TerraformDir: moduleDir, NoColor: true}
+
+	// Confirm every .tf file is already in canonical format; fmt -check lists the files it would rewrite
+	output, err := terraform.RunTerraformCommandE(t, options, "fmt", "-check", "-recursive", "-list=true")
+	assert.NoError(t, err, "Files need terraform fmt:\n%s", strings.TrimSpace(output))
+}
+
+func TestValidateProviderConstraints(t *testing.T) {
+	constraint, err := requiredTerraformVersion(moduleDir)
+	require.NoError(t, err, "Failed to read the module's required_version")
+	_, err = version.NewConstraint(constraint)
+	assert.NoError(t, err, "required_version %q is not a valid constraint", constraint)
+
+	providers, err := requiredProviders(moduleDir)
+	require.NoError(t, err, "Failed to read the module's required_providers")
+
+	// Confirm every provider is pinned to a source and a valid version constraint
+	for name, requirement := range providers {
+		assert.NotEmpty(t, requirement.Source, "Provider %s has no source", name)
+		if assert.NotEmpty(t, requirement.Version, "Provider %s has no version constraint", name) {
+			_, err := version.NewConstraint(requirement.Version)
+			assert.NoError(t, err, "Provider %s has an invalid version constraint %q", name, requirement.Version)
+		}
+	}
+}
+
+func TestValidatePlanGolden(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "plan.golden.json"))
+	require.NoError(t, err, "Failed to read the plan golden file")
+	var changes []plannedChange
+	require.NoError(t, json.Unmarshal(golden, &changes), "Failed to parse the plan golden file")
+	require.NotEmpty(t, changes, "The plan golden file records no changes")
+
+	declared, err := declaredResources(moduleDir)
+	require.NoError(t, err, "Failed to read the module's resources")
+
+	// Planning needs Azure credentials, so instead confirm every golden address is still declared by the module
+	for _, change := range changes {
+		address := countIndex.ReplaceAllString(change.Address, "")
+		assert.True(t, declared[address], "Golden file records %s but the module declares no such resource", change.Address)
+		assert.True(t, strings.HasPrefix(address, change.Type+"."), "Golden address %s does not match its type %s", change.Address, change.Type)
+	}
+}
+
+func TestVariableSchema(t *testing.T) {
+	problems, err := variableSchemaProblems(moduleDir)
+	require.NoError(t, err, "Failed to read the module's variables")
+
+	// Confirm every input is typed and documented for the module's consumers
+	assert.Empty(t, problems, "Variables are missing a type or description:\n  %s", strings.Join(problems, "\n  "))
+}
+
+func TestVariableSchemaProblems(t *testing.T) {
+	dir := t.TempDir()
+	variables := `variable "typed" {
+  type        = string
+  description = "Documented."
+}
+
+variable "untyped" {
+  description = "Documented."
+}
+
+variable "blank" {
+  type        = number
+  description = "  "
+}
+
+variable "bare" {}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(variables), 0o644))
+
+	problems, err := variableSchemaProblems(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"bare (variables.tf): no type or description",
+		"blank (variables.tf): no description",
+		"untyped (variables.tf): no type",
+	}, problems)
+}
+
+// variableSchemaProblems returns one line per variable in dir's .tf files that lacks an explicit type or a
+// non-empty description, sorted
+func variableSchemaProblems(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []string{}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for _, block := range parsed.Body.(*hclsyntax.Body).Blocks {
+			if block.Type != "variable" || len(block.Labels) != 1 {
+				continue
+			}
+			missing := []string{}
+			if _, ok := block.Body.Attributes["type"]; !ok {
+				missing = append(missing, "type")
+			}
+			description := ""
+			if attr, ok := block.Body.Attributes["description"]; ok {
+				if value, diags := attr.Expr.Value(nil); !diags.HasErrors() && value.Type() == cty.String && !value.IsNull() {
+					description = strings.TrimSpace(value.AsString())
+				}
+			}
+			if description == "" {
+				missing = append(missing, "description")
+			}
+			if len(missing) > 0 {
+				problems = append(problems, fmt.Sprintf("%s (%s): no %s", block.Labels[0], filepath.Base(file), strings.Join(missing, " or ")))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// sensitiveOutputs must be marked sensitive, as must any output named like a secret (see secretAttribute).
+// The module outputs no secrets today; list any it gains here.
+var sensitiveOutputs = []string{}
+
+// publicOutputs are read by tests and shown in CI logs, so they must not be marked sensitive
+var publicOutputs = []string{"public_ip", "vm_name", "resource_group_name"}
+
+func TestSensitiveOutputs(t *testing.T) {
+	sensitivity, err := outputSensitivity(moduleDir)
+	require.NoError(t, err, "Failed to read the module's outputs")
+
+	mismarked := []string{}
+	for name, sensitive := range sensitivity {
+		if !sensitive && (slices.Contains(sensitiveOutputs, name) || secretAttribute.MatchString(name)) {
+			mismarked = append(mismarked, name+" should be sensitive")
+		}
+	}
+	for _, name := range sensitiveOutputs {
+		if _, ok := sensitivity[name]; !ok {
+			mismarked = append(mismarked, name+" is not declared")
+		}
+	}
+	for _, name := range publicOutputs {
+		sensitive, ok := sensitivity[name]
+		switch {
+		case !ok:
+			mismarked = append(mismarked, name+" is not declared")
+		case sensitive:
+			mismarked = append(mismarked, name+" should not be sensitive")
+		}
+	}
+	sort.Strings(mismarked)
+
+	// Confirm secrets are kept out of logs and the outputs tests read are not
+	assert.Empty(t, mismarked, "Outputs are mismarked:\n  %s", strings.Join(mismarked, "\n  "))
+}
+
+func TestOutputSensitivity(t *testing.T) {
+	dir := t.TempDir()
+	outputs := `output "public_ip" {
+  value = "20.1.2.3"
+}
+
+output "admin_password" {
+  value     = "hunter2"
+  sensitive = true
+}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outputs.tf"), []byte(outputs), 0o644))
+
+	sensitivity, err := outputSensitivity(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"public_ip": false, "admin_password": true}, sensitivity)
+}
+
+// outputSensitivity maps each output declared in dir's .tf files to whether it is marked sensitive
+func outputSensitivity(dir string) (map[string]bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	sensitivity := map[string]bool{}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for _, block := range parsed.Body.(*hclsyntax.Body).Blocks {
+			if block.Type != "output" || len(block.Labels) != 1 {
+				continue
+			}
+			sensitive := false
+			if attr, ok := block.Body.Attributes["sensitive"]; ok {
+				value, diags := attr.Expr.Value(nil)
+				if diags.HasErrors() || value.Type() != cty.Bool || value.IsNull() {
+					return nil, fmt.Errorf("output %s in %s has a sensitive that is not true or false", block.Labels[0], filepath.Base(file))
+				}
+				sensitive = value.True()
+			}
+			sensitivity[block.Labels[0]] = sensitive
+		}
+	}
+	return sensitivity, nil
+}
+
+// declaredResources returns the addresses (type.name) of the managed resources declared in dir's .tf files
+func declaredResources(dir string) (map[string]bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	declared := map[string]bool{}
+	parser := hclparse.NewParser()
+	for _, file := range files {
+		parsed, diags := parser.ParseHCLFile(file)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for _, block := range parsed.Body.(*hclsyntax.Body).Blocks {
+			if block.Type == "resource" && len(block.Labels) == 2 {
+				declared[fmt.Sprintf("%s.%s", block.Labels[0], block.Labels[1])] = true
+			}
+		}
+	}
+	return declared, nil
+}
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vmSizeSpec is the CPU and memory a VM size provides
+type vmSizeSpec struct {
+	VCPUs     int
+	MemoryGiB float64
+}
+
+// vmSizeSpecs lists the sizes labs commonly use
+var vmSizeSpecs = map[string]vmSizeSpec{
+	"Standard_B1s":    {VCPUs: 1, MemoryGiB: 1},
+	"Standard_B1ms":   {VCPUs: 1, MemoryGiB: 2},
+	"Standard_B2s":    {VCPUs: 2, MemoryGiB: 4},
+	"Standard_B2ms":   {VCPUs: 2, MemoryGiB: 8},
+	"Standard_D2s_v3": {VCPUs: 2, MemoryGiB: 8},
+	"Standard_D2s_v5": {VCPUs: 2, MemoryGiB: 8},
+}
+
+// memoryTolerance is how far below the nominal size the guest's MemTotal may be,
+// since the kernel and firmware reserve part of the memory
+const memoryTolerance = 0.2
+
+// parseMemTotalKiB extracts MemTotal, in KiB, from the contents of /proc/meminfo
+func parseMemTotalKiB(meminfo string) (int, error) {
+	scanner := bufio.NewScanner(strings.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	return 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+}
@@ -0,0 +1,21 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMemTotalKiB(t *testing.T) {
+	meminfo := "MemTotal:         913400 kB\nMemFree:          412300 kB\nMemAvailable:     650100 kB\n"
+
+	memKiB, err := parseMemTotalKiB(meminfo)
+	require.NoError(t, err)
+	assert.Equal(t, 913400, memKiB)
+}
+
+func TestParseMemTotalKiBMissing(t *testing.T) {
+	_, err := parseMemTotalKiB("MemFree:          412300 kB\n")
+	assert.Error(t, err)
+}
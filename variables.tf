@@ -2,10 +2,38 @@
 variable "labelPrefix" {
   type        = string
   description = "Your college username. This will form the beginning of various resource names."
+
+  validation {
+    condition     = trimspace(var.labelPrefix) != ""
+    error_message = "The labelPrefix must not be empty or blank; every resource name starts with it."
+  }
+
+  validation {
+    condition     = can(regex("^[a-z][a-z0-9]{0,19}$", var.labelPrefix))
+    error_message = "The labelPrefix must start with a lowercase letter and contain only lowercase letters and digits, at most 20 characters."
+  }
 }
 
 variable "region" {
-  default = "westus3"
+  type        = string
+  default     = "westus3"
+  description = "The Azure region to deploy to, by name (westus3) or display name (West US 3)."
+
+  # A static list of public Azure regions, so a typo fails at plan time instead of partway through an apply
+  validation {
+    condition = contains([
+      "australiacentral", "australiacentral2", "australiaeast", "australiasoutheast", "austriaeast", "belgiumcentral",
+      "brazilsouth", "brazilsoutheast", "canadacentral", "canadaeast", "centralindia", "centralus", "chilecentral",
+      "eastasia", "eastus", "eastus2", "francecentral", "francesouth", "germanynorth", "germanywestcentral",
+      "indonesiacentral", "israelcentral", "italynorth", "japaneast", "japanwest", "jioindiacentral", "jioindiawest",
+      "koreacentral", "koreasouth", "malaysiawest", "mexicocentral", "newzealandnorth", "northcentralus", "northeurope",
+      "norwayeast", "norwaywest", "polandcentral", "qatarcentral", "southafricanorth", "southafricawest",
+      "southcentralus", "southeastasia", "southindia", "spaincentral", "swedencentral", "switzerlandnorth",
+      "switzerlandwest", "uaecentral", "uaenorth", "uksouth", "ukwest", "westcentralus", "westeurope", "westindia",
+      "westus", "westus2", "westus3",
+    ], lower(replace(var.region, " ", "")))
+    error_message = "The region \"${var.region}\" is not a known Azure region; use a name such as westus3 or eastus."
+  }
 }
 
 variable "admin_username" {
@@ -13,3 +41,443 @@ variable "admin_username" {
   default     = "azureadmin"
   description = "The username for the local user account on the VM."
 }
+
+variable "os_type" {
+  type        = string
+  default     = "linux"
+  description = "The VM's operating system: linux runs the Apache web server from cloud-init; windows deploys a bare Windows Server VM reachable over RDP."
+
+  validation {
+    condition     = contains(["linux", "windows"], var.os_type)
+    error_message = "The os_type must be linux or windows."
+  }
+}
+
+variable "admin_password" {
+  type        = string
+  default     = ""
+  sensitive   = true
+  description = "The Windows VM's administrator password. Required when os_type is windows; Linux VMs only accept the SSH key."
+
+  validation {
+    condition     = var.admin_password == "" || length(var.admin_password) >= 12
+    error_message = "The admin_password must be at least 12 characters."
+  }
+}
+
+variable "windows_image_sku" {
+  type        = string
+  default     = "2022-datacenter-azure-edition"
+  description = "The WindowsServer image SKU for a windows os_type."
+}
+
+variable "install_monitor_agent" {
+  type        = bool
+  default     = false
+  description = "Install the Azure Monitor agent extension on the VM."
+}
+
+variable "restrict_egress" {
+  type        = bool
+  default     = false
+  description = "Deny all outbound traffic from the web server except HTTP/HTTPS to the internet and traffic within the VNet. When false, Azure's default outbound rules apply."
+}
+
+variable "enable_bastion" {
+  type        = bool
+  default     = false
+  description = "Provision an Azure Bastion host (with its AzureBastionSubnet and public IP) for SSH access through the portal."
+}
+
+variable "vm_size" {
+  type        = string
+  default     = "Standard_B1s"
+  description = "The Azure VM size for the web server."
+}
+
+variable "architecture" {
+  type        = string
+  default     = "x64"
+  description = "The VM's CPU architecture: x64, or Arm64 for Ampere sizes (those with a p after the core count, such as Standard_D2ps_v5) with an -arm64 image_sku such as 22_04-lts-arm64."
+
+  validation {
+    condition     = contains(["x64", "Arm64"], var.architecture)
+    error_message = "The architecture must be x64 or Arm64."
+  }
+}
+
+variable "os_disk_caching" {
+  type        = string
+  default     = "ReadWrite"
+  description = "The caching mode for the VM's OS disk: None, ReadOnly or ReadWrite."
+
+  validation {
+    condition     = contains(["None", "ReadOnly", "ReadWrite"], var.os_disk_caching)
+    error_message = "The os_disk_caching must be one of None, ReadOnly or ReadWrite."
+  }
+}
+
+variable "ephemeral_os_disk" {
+  type        = bool
+  default     = false
+  description = "Put the OS disk on the host's local temp disk instead of a managed disk. Needs os_disk_caching = ReadOnly and a size with a large enough temp disk."
+}
+
+variable "custom_data_file" {
+  type        = string
+  default     = "init.sh"
+  description = "The bootstrap script passed to the VM as custom data, relative to the module directory."
+}
+
+variable "custom_data_marker" {
+  type        = string
+  default     = "/var/log/a05-init.done"
+  description = "The absolute path of a file the custom data script creates once it has run."
+
+  validation {
+    condition     = startswith(var.custom_data_marker, "/")
+    error_message = "The custom_data_marker must be an absolute path."
+  }
+}
+
+variable "data_disks" {
+  type = list(object({
+    size_gb = number
+    caching = string
+  }))
+  default     = []
+  description = "Empty data disks to attach, in LUN order: the first at LUN 0, the next at LUN 1 and so on."
+
+  validation {
+    condition     = alltrue([for disk in var.data_disks : disk.size_gb >= 1 && contains(["None", "ReadOnly", "ReadWrite"], disk.caching)])
+    error_message = "Every data disk needs a size_gb of at least 1 and a caching of None, ReadOnly or ReadWrite."
+  }
+}
+
+variable "disk_resource_group_name" {
+  type        = string
+  default     = ""
+  description = "A separate resource group to create for the data disks. Leave empty to keep them with the VM; the OS disk always stays in the VM's resource group."
+}
+
+variable "nic_count" {
+  type        = number
+  default     = 1
+  description = "The number of NICs attached to the VM. The first is primary and carries the public IP; the VM size must support the total."
+
+  validation {
+    condition     = var.nic_count >= 1 && var.nic_count <= 8 && floor(var.nic_count) == var.nic_count
+    error_message = "The nic_count must be a whole number between 1 and 8."
+  }
+}
+
+variable "image_offer" {
+  type        = string
+  default     = "0001-com-ubuntu-server-jammy"
+  description = "The Canonical image offer, e.g. 0001-com-ubuntu-server-focal for 20.04 or ubuntu-24_04-lts for 24.04."
+}
+
+variable "image_sku" {
+  type        = string
+  default     = "22_04-lts-gen2"
+  description = "The Ubuntu image SKU within image_offer. Gen2 SKUs end in -gen2."
+}
+
+variable "secure_boot_enabled" {
+  type        = bool
+  default     = false
+  description = "Enable UEFI secure boot (trusted launch). Requires a Gen2 image."
+}
+
+variable "vtpm_enabled" {
+  type        = bool
+  default     = false
+  description = "Enable the virtual TPM (trusted launch). Requires a Gen2 image."
+}
+
+variable "subnets" {
+  type = map(string)
+  default = {
+    web = "10.0.1.0/24"
+  }
+  description = "The VNet's subnets as name => address prefix, within 10.0.0.0/16. 10.0.2.0/26 is reserved for the bastion subnet, and app_gateway_subnet_prefix for the application gateway's when it is enabled."
+
+  validation {
+    condition     = length(var.subnets) > 0 && alltrue([for prefix in values(var.subnets) : can(cidrhost(prefix, 0))])
+    error_message = "The subnets must contain at least one subnet and every prefix must be a valid CIDR block."
+  }
+}
+
+variable "nic_subnet_name" {
+  type        = string
+  default     = "web"
+  description = "The name of the subnet in subnets that the VM's NICs attach to."
+}
+
+variable "secondary_private_ip" {
+  type        = string
+  default     = ""
+  description = "Add a second IP configuration to the NIC: \"Dynamic\" for an address Azure assigns, or a static address in the NIC's subnet. Leave empty for a single configuration."
+
+  validation {
+    condition     = contains(["", "Dynamic"], var.secondary_private_ip) || can(cidrhost("${var.secondary_private_ip}/32", 0))
+    error_message = "The secondary_private_ip must be empty, Dynamic or an IPv4 address."
+  }
+}
+
+variable "plan_name" {
+  type        = string
+  default     = ""
+  description = "The marketplace plan (image SKU) to deploy. Leave empty for the default Ubuntu image, which has no plan."
+}
+
+variable "plan_publisher" {
+  type        = string
+  default     = ""
+  description = "The marketplace plan's publisher. Required with plan_name."
+}
+
+variable "plan_product" {
+  type        = string
+  default     = ""
+  description = "The marketplace plan's product (image offer). Required with plan_name."
+}
+
+variable "disk_controller_type" {
+  type        = string
+  default     = "SCSI"
+  description = "The VM's disk controller: SCSI or NVMe. NVMe needs a Gen2 image and a size that supports it."
+
+  validation {
+    condition     = contains(["SCSI", "NVMe"], var.disk_controller_type)
+    error_message = "The disk_controller_type must be SCSI or NVMe."
+  }
+}
+
+variable "enable_private_dns" {
+  type        = bool
+  default     = false
+  description = "Create a private DNS zone and link it to the VNet."
+}
+
+variable "private_dns_zone_name" {
+  type        = string
+  default     = "a05.internal"
+  description = "The name of the private DNS zone created when enable_private_dns is true."
+}
+
+variable "private_dns_auto_registration" {
+  type        = bool
+  default     = true
+  description = "Automatically register VM records from the VNet in the private DNS zone."
+}
+
+variable "resource_group_suffix" {
+  type        = string
+  default     = ""
+  description = "Appended to the resource group name so several deployments with the same labelPrefix can coexist, each in its own resource group."
+
+  validation {
+    condition     = can(regex("^[a-z0-9-]{0,40}$", var.resource_group_suffix))
+    error_message = "The resource_group_suffix must contain only lowercase letters, digits and hyphens, at most 40 characters."
+  }
+}
+
+variable "enable_https" {
+  type        = bool
+  default     = false
+  description = "Serve the site over HTTPS with a self-signed certificate, open port 443 and redirect HTTP to HTTPS."
+}
+
+variable "enable_metrics" {
+  type        = bool
+  default     = false
+  description = "Install the Prometheus node exporter and serve its metrics at /metrics on the web server."
+}
+
+variable "gzip_enabled" {
+  type        = bool
+  default     = true
+  description = "Compress text responses with gzip when clients ask for it (Apache's mod_deflate). Set to false to serve them uncompressed."
+}
+
+variable "private_endpoint_policies_enabled" {
+  type        = bool
+  default     = true
+  description = "Apply network policies to private endpoints in the subnets. Must be false for subnets that host private endpoints."
+}
+
+variable "hyper_v_generation" {
+  type        = string
+  default     = ""
+  description = "The hypervisor generation (V1 or V2) the image is expected to boot as. Leave empty to derive it from image_sku; set it for images whose SKU doesn't say."
+
+  validation {
+    condition     = contains(["", "V1", "V2"], var.hyper_v_generation)
+    error_message = "The hyper_v_generation must be V1, V2 or empty."
+  }
+}
+
+variable "zone" {
+  type        = string
+  default     = ""
+  description = "The availability zone (1, 2 or 3) to place the VM and its OS disk in. Leave empty for a regional deployment."
+
+  validation {
+    condition     = contains(["", "1", "2", "3"], var.zone)
+    error_message = "The zone must be 1, 2, 3 or empty."
+  }
+}
+
+variable "public_ip_enabled" {
+  type        = bool
+  default     = true
+  description = "Give the VM a public IP. Disable for a private-only VM reached through Bastion."
+}
+
+variable "public_ip_allocation" {
+  type        = string
+  default     = "Dynamic"
+  description = "The public IP allocation method. A Dynamic IP can change when the VM is deallocated; a Static one is kept."
+
+  validation {
+    condition     = contains(["Dynamic", "Static"], var.public_ip_allocation)
+    error_message = "The public_ip_allocation must be Dynamic or Static."
+  }
+}
+
+variable "public_ip_sku" {
+  type        = string
+  default     = "Basic"
+  description = "The public IP SKU. A Standard IP must be Static and follows the VM's zone, or spans all three zones when no zone is set; a Basic IP has no zones."
+
+  validation {
+    condition     = contains(["Basic", "Standard"], var.public_ip_sku)
+    error_message = "The public_ip_sku must be Basic or Standard."
+  }
+}
+
+variable "created_at" {
+  type        = string
+  default     = ""
+  description = "The RFC3339 time for the created_at tag the cleanup janitor ages resources by. Leave empty to stamp the time of the first apply."
+
+  validation {
+    condition     = var.created_at == "" || can(formatdate("YYYY", var.created_at))
+    error_message = "The created_at must be an RFC3339 timestamp such as 2024-03-01T12:00:00Z."
+  }
+}
+
+variable "auto_shutdown_time" {
+  type        = string
+  default     = ""
+  description = "The daily time (HHmm, e.g. 1900) to shut the VM down. Leave empty for no auto-shutdown schedule."
+
+  validation {
+    condition     = can(regex("^(([01][0-9]|2[0-3])[0-5][0-9])?$", var.auto_shutdown_time))
+    error_message = "The auto_shutdown_time must be a 24-hour HHmm time such as 1900, or empty."
+  }
+}
+
+variable "auto_shutdown_timezone" {
+  type        = string
+  default     = "UTC"
+  description = "The Windows time zone ID auto_shutdown_time is in, e.g. Eastern Standard Time."
+}
+
+variable "shared_image_id" {
+  type        = string
+  default     = ""
+  description = "The ID of a shared image gallery image or image version to boot from instead of the marketplace image."
+
+  validation {
+    condition     = var.shared_image_id == "" || can(regex("(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft.Compute/galleries/[^/]+/images/[^/]+(/versions/[^/]+)?$", var.shared_image_id))
+    error_message = "The shared_image_id must be a gallery image ID such as /subscriptions/.../providers/Microsoft.Compute/galleries/<gallery>/images/<image>."
+  }
+}
+
+variable "public_ip_idle_timeout" {
+  type        = number
+  default     = 4
+  description = "Minutes an idle TCP connection through the public IP is kept open before Azure drops it."
+
+  validation {
+    condition     = var.public_ip_idle_timeout >= 4 && var.public_ip_idle_timeout <= 30
+    error_message = "The public_ip_idle_timeout must be between 4 and 30 minutes."
+  }
+}
+
+variable "enable_load_balancer" {
+  type        = bool
+  default     = false
+  description = "Serve the site through a Standard load balancer with an HTTP health probe. Requires public_ip_enabled = false or a Standard public_ip_sku."
+}
+
+variable "enable_app_gateway" {
+  type        = bool
+  default     = false
+  description = "Also serve the site through a Standard_v2 application gateway with an HTTP listener, in its own subnet (app_gateway_subnet_prefix)."
+}
+
+variable "app_gateway_subnet_prefix" {
+  type        = string
+  default     = "10.0.4.0/24"
+  description = "The address prefix of the application gateway's subnet, within 10.0.0.0/16 and clear of the subnets."
+
+  validation {
+    condition     = can(cidrhost(var.app_gateway_subnet_prefix, 0))
+    error_message = "The app_gateway_subnet_prefix must be a valid CIDR block."
+  }
+}
+
+variable "enable_nsg_diagnostics" {
+  type        = bool
+  default     = false
+  description = "Send the NSG's event and rule counter logs to a new Log Analytics workspace."
+}
+
+variable "license_type" {
+  type        = string
+  default     = ""
+  description = "The license to bill the VM under, such as RHEL_BYOS or SLES_BYOS for Azure Hybrid Benefit. Only valid for images from the matching publisher; leave empty for the image's default."
+
+  validation {
+    condition     = contains(["", "RHEL_BYOS", "RHEL_BASE", "RHEL_SAPAPPS", "RHEL_SAPHA", "RHEL_BASESAPAPPS", "RHEL_BASESAPHA", "SLES_BYOS", "SLES_SAP", "SLES_HPC", "SLES_STANDARD", "UBUNTU_PRO", "UBUNTU"], var.license_type)
+    error_message = "The license_type must be a RHEL_*, SLES_* or UBUNTU* license type, or empty."
+  }
+}
+
+variable "enable_ppg" {
+  type        = bool
+  default     = false
+  description = "Place the VM in a new proximity placement group, for low-latency labs that add VMs alongside it."
+}
+
+variable "web_server_workers" {
+  type        = number
+  default     = 2
+  description = "The number of Apache worker processes to run; cloud-init pins the event MPM to exactly this many."
+
+  validation {
+    condition     = var.web_server_workers >= 1 && var.web_server_workers <= 16 && floor(var.web_server_workers) == var.web_server_workers
+    error_message = "The web_server_workers must be a whole number between 1 and 16."
+  }
+}
+
+variable "dns_zone_name" {
+  type        = string
+  default     = ""
+  description = "An existing public DNS zone to add an A record for the VM to, such as labs.example.com. Leave empty for no record."
+}
+
+variable "dns_zone_resource_group" {
+  type        = string
+  default     = ""
+  description = "The resource group holding dns_zone_name. Required with dns_zone_name."
+}
+
+variable "dns_record_name" {
+  type        = string
+  default     = "www"
+  description = "The A record's name within dns_zone_name; @ for the zone apex."
+}